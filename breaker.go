@@ -0,0 +1,172 @@
+package easysql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker settings, see WithCircuitBreaker. zero fields take the defaults.
+type CircuitBreaker struct {
+	FailureRatio   float64       //ratio of connection/timeout errors that trips the breaker, default 0.5
+	MinRequests    int           //requests needed in a window before the ratio is considered, default 20
+	Window         time.Duration //errors are counted over this period, default 10s
+	OpenTimeout    time.Duration //how long the breaker stays open before probing, default 5s
+	HalfOpenProbes int           //successful probes needed to close again, default 1
+}
+
+// WithCircuitBreaker makes the DBServer fail fast with ErrCircuitOpen during outages,
+// instead of piling up goroutines waiting on the pool.
+// the breaker trips when the ratio of connection and timeout errors (IsConnectionError, IsTimeout)
+// reaches FailureRatio. after OpenTimeout it lets HalfOpenProbes statements through,
+// and closes if they succeed or opens again if one fails.
+// other errors, like constraint violations, count as successes.
+func WithCircuitBreaker(cfg CircuitBreaker) Option {
+	return func(o *Options) {
+		o.CircuitBreaker = &cfg
+	}
+}
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// Breaker is the hook installed by WithCircuitBreaker.
+type Breaker struct {
+	cfg CircuitBreaker
+
+	mu          sync.Mutex
+	state       BreakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int //probes in flight
+	successes   int //successful probes
+}
+
+func NewBreaker(cfg CircuitBreaker) *Breaker {
+	if cfg.FailureRatio <= 0 {
+		cfg.FailureRatio = 0.5
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 20
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 5 * time.Second
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	return &Breaker{cfg: cfg, windowStart: time.Now()}
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cfg.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+type probeKey struct{}
+
+func (b *Breaker) Before(ctx context.Context, ev *QueryEvent) (context.Context, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if time.Since(b.openedAt) < b.cfg.OpenTimeout {
+			return ctx, ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probes, b.successes = 0, 0
+	}
+
+	//the statements of a transaction are the probes, not the transaction itself
+	if b.state == BreakerHalfOpen && ev.Op != OpTx {
+		if b.probes+b.successes >= b.cfg.HalfOpenProbes {
+			return ctx, ErrCircuitOpen
+		}
+		b.probes++
+		return context.WithValue(ctx, probeKey{}, true), nil
+	}
+	return ctx, nil
+}
+
+func (b *Breaker) After(ctx context.Context, ev *QueryEvent) {
+	rejected := errors.Is(ev.Err, ErrCircuitOpen)
+	failed := !rejected && (IsConnectionError(ev.Err) || IsTimeout(ev.Err))
+	probe, _ := ctx.Value(probeKey{}).(bool)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		if b.state != BreakerHalfOpen {
+			return
+		}
+		b.probes--
+		if rejected {
+			return
+		}
+		if failed {
+			b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenProbes {
+			b.close()
+		}
+		return
+	}
+
+	//a whole transaction repeats the errors of its statements
+	if rejected || b.state != BreakerClosed || ev.Op == OpTx {
+		return
+	}
+
+	if time.Since(b.windowStart) >= b.cfg.Window {
+		b.windowStart = time.Now()
+		b.requests, b.failures = 0, 0
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if b.requests >= b.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= b.cfg.FailureRatio {
+		b.open()
+	}
+}
+
+func (b *Breaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = time.Now()
+	StdLogger.Warn("easysql: circuit breaker is open", "requests", b.requests, "failures", b.failures)
+}
+
+func (b *Breaker) close() {
+	b.state = BreakerClosed
+	b.windowStart = time.Now()
+	b.requests, b.failures = 0, 0
+	b.probes, b.successes = 0, 0
+}
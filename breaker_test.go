@@ -0,0 +1,219 @@
+package easysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errConn = &Error{Code: CodeConnectionFailure, Message: "connection refused"}
+
+func runOp(hooks *Hooks, op string, err error) (called bool, out error) {
+	out = hooks.Run(context.Background(), &QueryEvent{Op: op}, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		called = true
+		return -1, err
+	})
+	return called, out
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Info(msg string, args ...interface{})  {}
+func (discardLogger) Warn(msg string, args ...interface{})  {}
+func (discardLogger) Error(msg string, args ...interface{}) {}
+
+func newTestBreaker(t *testing.T, cfg CircuitBreaker) (*Breaker, *Hooks) {
+	logger := StdLogger
+	StdLogger = discardLogger{}
+	t.Cleanup(func() { StdLogger = logger })
+
+	b := NewBreaker(cfg)
+	return b, NewHooks(b)
+}
+
+func TestBreakerTrips(t *testing.T) {
+	b, hooks := newTestBreaker(t, CircuitBreaker{FailureRatio: 0.5, MinRequests: 4, Window: time.Minute, OpenTimeout: time.Minute})
+
+	runOp(hooks, OpExec, nil)
+	runOp(hooks, OpExec, errConn)
+	//constraint violations are successes
+	runOp(hooks, OpExec, &Error{Code: CodeUniqueViolation})
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s before MinRequests, want closed", b.State())
+	}
+
+	runOp(hooks, OpExec, errConn)
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s at 2/4 failures, want open", b.State())
+	}
+}
+
+func TestBreakerBelowRatio(t *testing.T) {
+	b, hooks := newTestBreaker(t, CircuitBreaker{FailureRatio: 0.5, MinRequests: 4, Window: time.Minute, OpenTimeout: time.Minute})
+
+	for i := 0; i < 10; i++ {
+		err := error(nil)
+		if i%4 == 0 {
+			err = errConn
+		}
+		runOp(hooks, OpQuery, err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s at 3/10 failures, want closed", b.State())
+	}
+}
+
+func TestBreakerWindow(t *testing.T) {
+	b, hooks := newTestBreaker(t, CircuitBreaker{FailureRatio: 0.5, MinRequests: 3, Window: 20 * time.Millisecond, OpenTimeout: time.Minute})
+
+	runOp(hooks, OpExec, errConn)
+	time.Sleep(30 * time.Millisecond)
+	//the failure of the previous window is forgotten, 1/3 instead of 2/4
+	runOp(hooks, OpExec, nil)
+	runOp(hooks, OpExec, errConn)
+	runOp(hooks, OpExec, nil)
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s, want closed", b.State())
+	}
+}
+
+func TestBreakerFailsFast(t *testing.T) {
+	_, hooks := newTestBreaker(t, CircuitBreaker{FailureRatio: 0.5, MinRequests: 1, OpenTimeout: time.Minute})
+
+	runOp(hooks, OpExec, errConn)
+	for _, op := range []string{OpExec, OpQuery, OpTx} {
+		called, err := runOp(hooks, op, nil)
+		if called {
+			t.Errorf("%s reached the database while the breaker is open", op)
+		}
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("%s: err = %v, want ErrCircuitOpen", op, err)
+		}
+	}
+}
+
+func TestBreakerHalfOpenCloses(t *testing.T) {
+	b, hooks := newTestBreaker(t, CircuitBreaker{FailureRatio: 0.5, MinRequests: 1, OpenTimeout: 20 * time.Millisecond, HalfOpenProbes: 2})
+
+	runOp(hooks, OpExec, errConn)
+	time.Sleep(30 * time.Millisecond)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state = %s after OpenTimeout, want half-open", b.State())
+	}
+
+	if called, err := runOp(hooks, OpQuery, nil); !called || err != nil {
+		t.Fatalf("first probe: called %v, err %v", called, err)
+	}
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state = %s after 1 of 2 probes, want half-open", b.State())
+	}
+	if called, err := runOp(hooks, OpQuery, nil); !called || err != nil {
+		t.Fatalf("second probe: called %v, err %v", called, err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s after the probes, want closed", b.State())
+	}
+
+	//a closed breaker starts counting again from zero
+	if called, _ := runOp(hooks, OpQuery, nil); !called {
+		t.Fatal("closed breaker rejected a statement")
+	}
+}
+
+func TestBreakerHalfOpenLimit(t *testing.T) {
+	b, hooks := newTestBreaker(t, CircuitBreaker{FailureRatio: 0.5, MinRequests: 1, OpenTimeout: 20 * time.Millisecond, HalfOpenProbes: 1})
+
+	runOp(hooks, OpExec, errConn)
+	time.Sleep(30 * time.Millisecond)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- hooks.Run(context.Background(), &QueryEvent{Op: OpQuery}, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+	}()
+	<-started
+
+	//the only probe is in flight
+	called, err := runOp(hooks, OpQuery, nil)
+	if called || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second statement while probing: called %v, err %v, want ErrCircuitOpen", called, err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s after the probe succeeded, want closed", b.State())
+	}
+}
+
+func TestBreakerProbeFails(t *testing.T) {
+	b, hooks := newTestBreaker(t, CircuitBreaker{FailureRatio: 0.5, MinRequests: 1, OpenTimeout: 20 * time.Millisecond, HalfOpenProbes: 2})
+
+	runOp(hooks, OpExec, errConn)
+	time.Sleep(30 * time.Millisecond)
+
+	runOp(hooks, OpQuery, nil)
+	if called, _ := runOp(hooks, OpQuery, errConn); !called {
+		t.Fatal("second probe was rejected")
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s after a failed probe, want open", b.State())
+	}
+	if called, err := runOp(hooks, OpQuery, nil); called || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after reopening: called %v, err %v, want ErrCircuitOpen", called, err)
+	}
+
+	//and probes again after OpenTimeout
+	time.Sleep(30 * time.Millisecond)
+	runOp(hooks, OpQuery, nil)
+	runOp(hooks, OpQuery, nil)
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s, want closed", b.State())
+	}
+}
+
+func TestBreakerIgnoresTx(t *testing.T) {
+	b, hooks := newTestBreaker(t, CircuitBreaker{FailureRatio: 0.5, MinRequests: 2, OpenTimeout: 20 * time.Millisecond, HalfOpenProbes: 1})
+
+	//a failed transaction repeats the error of its statement, which is counted on its own
+	runOp(hooks, OpTx, errConn)
+	runOp(hooks, OpTx, errConn)
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s after failed transactions, want closed", b.State())
+	}
+
+	runOp(hooks, OpExec, errConn)
+	runOp(hooks, OpExec, errConn)
+	time.Sleep(30 * time.Millisecond)
+
+	//in half-open the transaction isn't a probe, its first statement is
+	var inner error
+	var innerCalled bool
+	err := hooks.Run(context.Background(), &QueryEvent{Op: OpTx}, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		innerCalled, inner = runOp(hooks, OpExec, nil)
+		return -1, inner
+	})
+	if err != nil || !innerCalled {
+		t.Fatalf("transaction in half-open: err %v, statement called %v", err, innerCalled)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s after the statement of the transaction succeeded, want closed", b.State())
+	}
+}
+
+func TestBreakerState(t *testing.T) {
+	names := map[BreakerState]string{BreakerClosed: "closed", BreakerOpen: "open", BreakerHalfOpen: "half-open"}
+	for state, want := range names {
+		if got := state.String(); got != want {
+			t.Errorf("%d.String() = %q, want %q", state, got, want)
+		}
+	}
+}
@@ -3,45 +3,80 @@ package clickhouse
 import (
 	"context"
 	"database/sql"
-	"encoding/base64"
-	"math/rand"
-	"strconv"
 	"strings"
 	"time"
 
 	_ "github.com/ClickHouse/clickhouse-go"
+	"github.com/carr123/easysql"
 	"github.com/jmoiron/sqlx"
 )
 
 type DBServer struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	opts     *easysql.Options
+	hooks    *easysql.Hooks
+	replicas *easysql.ReplicaSet //nil without replicas
 }
 
 type Conn struct {
-	db     *sqlx.DB
-	tx     *sqlx.Tx
-	excter execAndQuery
-	ctx    context.Context
+	srv       *DBServer
+	db        *sqlx.DB
+	tx        *sqlx.Tx
+	excter    execAndQuery
+	ctx       context.Context
+	callbacks *easysql.TxCallbacks //nil outside a transaction
+	primary   bool                 //reads skip the replicas
+	timeout   time.Duration        //statement timeout, 0 means none
 }
 
-type QItem map[string]interface{}
-type QArray []QItem
+//QueryEvent.Backend
+const backendName = "clickhouse"
+
+type QItem = easysql.QItem
+type QArray = easysql.QArray
+
+var (
+	_ easysql.DB   = (*DBServer)(nil)
+	_ easysql.Conn = (*Conn)(nil)
+)
 
 type execAndQuery interface {
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
 	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 }
 
 func New(dataSourceName string, MaxIdleConn int) (*DBServer, error) {
-	inst := &DBServer{}
-	db, err := sqlx.Connect("clickhouse", dataSourceName)
+	return NewWithOptions(dataSourceName, easysql.WithMaxIdleConns(MaxIdleConn))
+}
+
+func NewWithOptions(dataSourceName string, opts ...easysql.Option) (*DBServer, error) {
+	opts = append([]easysql.Option{easysql.WithConnMaxLifetime(time.Second * 300)}, opts...)
+	o := easysql.NewOptions(opts...)
+	o.AddConvertDSN(func(dsn string) (string, error) {
+		return withMaxExecutionTime(dsn, o.QueryTimeout)
+	})
+	dsn, err := withMaxExecutionTime(dataSourceName, o.QueryTimeout)
 	if err != nil {
 		return nil, err
 	}
-	db.SetMaxIdleConns(MaxIdleConn)
-	db.SetConnMaxLifetime(time.Second * 300)
+
+	db, err := easysql.Connect("clickhouse", dsn, o)
+	if err != nil {
+		return nil, err
+	}
+	replicas, err := easysql.NewReplicaSet("clickhouse", backendName, o)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	inst := &DBServer{}
 	inst.db = db
+	inst.replicas = replicas
+	inst.opts = o
+	inst.hooks = easysql.NewHooks(o.ServerHooks(backendName, db, replicas)...)
 	return inst, nil
 }
 
@@ -50,256 +85,462 @@ func (this *DBServer) Close() {
 		this.db.Close()
 		this.db = nil
 	}
+	this.replicas.Close()
+	this.replicas = nil
 }
 
-func (this *DBServer) NewConn() *Conn {
-	return &Conn{db: this.db, tx: nil, excter: this.db, ctx: context.Background()}
+//add hooks called around every statement and transaction, see easysql.Hook.
+//hooks are meant to be added at startup, before the DBServer is shared.
+func (this *DBServer) Use(hooks ...easysql.Hook) {
+	this.hooks.Use(hooks...)
 }
 
-func (this *Conn) Context() context.Context {
-	if this.ctx != nil {
-		return this.ctx
-	}
-	return context.Background()
+//statistics of the connection pool, see also easysql.WithMetrics
+func (this *DBServer) Stats() sql.DBStats {
+	return this.db.Stats()
 }
 
-func (this *Conn) WithContext(ctx context.Context) *Conn {
-	if ctx == nil {
-		panic("nil context")
-	}
-	conn2 := &Conn{db: this.db, tx: this.tx, excter: this.excter, ctx: ctx}
-	return conn2
+func (this *DBServer) NewConn() easysql.Conn {
+	return &Conn{srv: this, db: this.db, tx: nil, excter: this.db, ctx: context.Background(), timeout: this.opts.QueryTimeout}
 }
 
-func (this *Conn) Ping() error {
-	return this.db.Ping()
+//clickhouse has no real transactions. statements issued through conn are
+//sent on a single connection and inserts are flushed as one block on commit.
+func (this *DBServer) ExecInTx(fn func(easysql.Conn) error) error {
+	ctx := context.Background()
+	return this.ExecInTxContext(ctx, fn)
 }
 
-//update delete
-//create table, alter index etc.
-func (this *Conn) Exec(cmd string, args ...interface{}) error {
-	query, argsx, err := sqlx.In(cmd, args...)
-	if err != nil {
-		return err
-	}
+func (this *DBServer) ExecInTxContext(ctx context.Context, fn func(easysql.Conn) error) error {
+	return this.ExecInTxWithOptions(ctx, nil, fn)
+}
 
-	query = this.db.Rebind(query)
-	_, err = this.excter.ExecContext(this.Context(), query, argsx...)
+//options are ignored, clickhouse has no isolation levels.
+func (this *DBServer) ExecInTxWithOptions(ctx context.Context, opts *easysql.TxOptions, fn func(easysql.Conn) error) error {
+	var callbacks *easysql.TxCallbacks
+	err := this.opts.RetryPolicy.Do(ctx, isRetryable, func() error {
+		callbacks = easysql.NewTxCallbacks()
+		ev := &easysql.QueryEvent{Op: easysql.OpTx, Backend: backendName, InTx: true}
+		return this.hooks.Run(ctx, ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+			return -1, this.execInTx(ctx, opts, callbacks, fn)
+		})
+	})
+	callbacks.Fire(err)
 	return err
 }
 
-func (this *Conn) BulkInsertEx(cmd string, nCol int, args []interface{}, szSQLsurfix ...string) error {
-	var szSQL string
-	szBracket := "(" + strings.TrimSuffix(strings.Repeat("?,", nCol), ",") + "),"
-	szSQL = cmd + " values " + szBracket
-	if len(szSQLsurfix) > 0 {
-		szSQL = szSQL + " " + strings.Join(szSQLsurfix, " ")
+func (this *DBServer) execInTx(ctx context.Context, opts *easysql.TxOptions, callbacks *easysql.TxCallbacks, fn func(easysql.Conn) error) error {
+	tx, err := this.begin(ctx, opts)
+	if err != nil {
+		return err
 	}
 
-	tx, err := this.db.BeginTx(this.ctx, nil)
-	if err != nil {
+	conn := &Conn{srv: this, db: this.db, tx: tx, excter: tx, ctx: ctx, callbacks: callbacks, timeout: this.opts.QueryTimeout}
+	if err := fn(conn); err != nil {
+		tx.Rollback()
 		return err
 	}
+	return wrapError(tx.Commit(), "COMMIT")
+}
 
-	stmt, err := tx.Prepare(szSQL)
+func (this *DBServer) begin(ctx context.Context, opts *easysql.TxOptions) (*sqlx.Tx, error) {
+	tx, err := this.db.BeginTxx(ctx, nil)
+	return tx, wrapError(err, "BEGIN")
+}
+
+//manual transaction for long running work, e.g. committing every N records.
+//the caller must call Commit or Rollback, and should defer tx.Close() as a safety net.
+//unlike ExecInTx, failed transactions are not retried.
+func (this *DBServer) Begin(ctx context.Context, opts *easysql.TxOptions) (*easysql.Tx, error) {
+	var tx *sqlx.Tx
+	ev := &easysql.QueryEvent{Op: easysql.OpBegin, Backend: backendName, Query: "BEGIN", InTx: true}
+	err := this.hooks.Run(ctx, ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		var err error
+		tx, err = this.begin(ctx, opts)
+		return -1, err
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer stmt.Close()
 
-	for i := 0; i < len(args); i += nCol {
-		if _, err := stmt.Exec(args[i : i+nCol]...); err != nil {
-			return err
-		}
+	callbacks := easysql.NewTxCallbacks()
+	conn := &Conn{srv: this, db: this.db, tx: tx, excter: tx, ctx: ctx, callbacks: callbacks, timeout: this.opts.QueryTimeout}
+	commit := func() error {
+		return this.endTx(ctx, easysql.OpCommit, "COMMIT", tx.Commit)
 	}
+	rollback := func() error {
+		return this.endTx(ctx, easysql.OpRollback, "ROLLBACK", tx.Rollback)
+	}
+	return easysql.NewTx(conn, callbacks, commit, rollback), nil
+}
 
-	if err := tx.Commit(); err != nil {
-		return err
+func (this *DBServer) endTx(ctx context.Context, op string, query string, fn func() error) error {
+	ev := &easysql.QueryEvent{Op: op, Backend: backendName, Query: query, InTx: true}
+	return this.hooks.Run(ctx, ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return -1, wrapError(fn(), query)
+	})
+}
+
+//clickhouse has no transaction conflicts, only a custom RetryPolicy.Retryable retries
+func isRetryable(err error) bool {
+	return false
+}
+
+func (this *Conn) Context() context.Context {
+	if this.ctx != nil {
+		return this.ctx
 	}
+	return context.Background()
+}
 
-	return nil
+func (this *Conn) WithContext(ctx context.Context) easysql.Conn {
+	if ctx == nil {
+		panic("nil context")
+	}
+	conn2 := *this
+	conn2.ctx = ctx
+	return &conn2
 }
 
-func MakeQArray() QArray {
-	return make(QArray, 0)
+//reads of the returned conn go to the primary, to read your own writes when replicas are configured.
+func (this *Conn) ForcePrimary() easysql.Conn {
+	conn2 := *this
+	conn2.primary = true
+	return &conn2
 }
 
-//query database
-func (this *Conn) Query(query string, args ...interface{}) (QArray, error) {
-	queryx, argsx, err := sqlx.In(query, args...)
-	if err != nil {
-		return nil, err
+//statements of the returned conn time out after d, 0 means no timeout.
+//conn.WithTimeout(time.Second).Query("select ...")
+func (this *Conn) WithTimeout(d time.Duration) easysql.Conn {
+	conn2 := *this
+	conn2.timeout = d
+	return &conn2
+}
+
+//reads outside a transaction go to a replica, see easysql.WithReplicas.
+//after a connection error on the replica, the read is retried on the primary.
+func (this *Conn) read(ctx context.Context, fn func(q execAndQuery) (int64, error)) (int64, error) {
+	if this.tx != nil || this.primary {
+		return fn(this.excter)
 	}
-	queryx = this.db.Rebind(queryx)
 
-	rows, err := this.excter.QueryxContext(this.Context(), queryx, argsx...)
-	if err != nil {
-		return nil, err
+	replica := this.srv.replicas.Reader()
+	if replica == nil {
+		return fn(this.excter)
 	}
-	defer rows.Close()
 
-	result := make(QArray, 0, 100)
-	for rows.Next() {
-		out := make(map[string]interface{})
-		err := rows.MapScan(out)
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, out)
+	n, err := fn(replica)
+	if err != nil && retryRead(ctx, err) {
+		this.srv.replicas.MarkDown(replica)
+		return fn(this.excter)
 	}
+	return n, err
+}
 
-	return result, nil
+//a failed read is retried elsewhere after a connection error only,
+//never once the statement context has ended or after a timeout.
+func retryRead(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	err = wrapError(err, "")
+	return easysql.IsConnectionError(err) && !easysql.IsTimeout(err)
 }
 
-//query database
-func (this *Conn) Select(dest interface{}, query string, args ...interface{}) error {
-	queryx, argsx, err := sqlx.In(query, args...)
-	if err != nil {
-		return err
+//outside a transaction it starts one like DBServer.ExecInTx.
+//inside a transaction fn just runs on the same connection, clickhouse has no savepoints.
+func (this *Conn) ExecInTx(fn func(easysql.Conn) error) error {
+	if this.tx == nil {
+		return this.srv.ExecInTxContext(this.Context(), fn)
 	}
-	queryx = this.db.Rebind(queryx)
-	return this.excter.SelectContext(this.Context(), dest, queryx, argsx...)
+	return fn(this)
 }
 
-//select count(*) from ...
-func (this *Conn) QueryCount(query string, args ...interface{}) (int64, error) {
+//run fn once the transaction of this conn has committed.
+//outside a transaction fn runs immediately.
+//if the transaction is retried, only the callbacks of the final attempt run.
+func (this *Conn) AfterCommit(fn func()) {
+	if this.callbacks == nil {
+		fn()
+		return
+	}
+	this.callbacks.AfterCommit(fn)
+}
+
+//run fn once the transaction of this conn has rolled back.
+//err is the reason, nil after an explicit Tx.Rollback. outside a transaction fn never runs.
+func (this *Conn) AfterRollback(fn func(err error)) {
+	if this.callbacks == nil {
+		return
+	}
+	this.callbacks.AfterRollback(fn)
+}
+
+func (this *Conn) Ping() error {
+	return wrapError(this.db.PingContext(this.Context()), "")
+}
+
+//expand slices for IN clauses and rebind the placeholders
+func (this *Conn) bind(query string, args []interface{}) (string, []interface{}, error) {
 	queryx, argsx, err := sqlx.In(query, args...)
 	if err != nil {
-		return 0, err
+		return "", nil, err
 	}
-	queryx = this.db.Rebind(queryx)
+	return this.db.Rebind(queryx), argsx, nil
+}
+
+//run fn between the hooks. fn gets the query and args as modified by the hooks,
+//and the context to pass to the driver.
+func (this *Conn) run(op string, query string, args []interface{}, fn func(ctx context.Context, query string, args []interface{}) (int64, error)) error {
+	ctx, cancel := easysql.TimeoutContext(this.Context(), this.timeout)
+	defer cancel()
+	return this.runContext(ctx, op, query, args, fn)
+}
+
+//run with a context whose lifetime is managed by the caller
+func (this *Conn) runContext(ctx context.Context, op string, query string, args []interface{}, fn func(ctx context.Context, query string, args []interface{}) (int64, error)) error {
+	ev := &easysql.QueryEvent{Op: op, Backend: backendName, Query: query, Args: args, InTx: this.tx != nil}
+	return this.srv.hooks.Run(ctx, ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		n, err := fn(ctx, this.maxExecutionTime(query), args)
+		return n, wrapError(err, query)
+	})
+}
+
+//update delete
+//create table, alter index etc.
+func (this *Conn) Exec(cmd string, args ...interface{}) error {
+	_, err := this.ExecResult(cmd, args...)
+	return err
+}
 
-	rows, err := this.excter.QueryxContext(this.Context(), queryx, argsx...)
+//same as Exec, also reports the number of affected rows
+func (this *Conn) ExecResult(cmd string, args ...interface{}) (easysql.Result, error) {
+	return this.execResult(easysql.OpExec, cmd, args)
+}
+
+func (this *Conn) execResult(op string, cmd string, args []interface{}) (easysql.Result, error) {
+	query, argsx, err := this.bind(cmd, args)
 	if err != nil {
-		return 0, err
+		return easysql.Result{}, err
 	}
-	defer rows.Close()
 
-	var count int64 = 0
-	for rows.Next() {
-		err := rows.Scan(&count)
+	var res easysql.Result
+	err = this.run(op, query, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		r, err := this.excter.ExecContext(ctx, query, args...)
 		if err != nil {
-			return 0, err
+			return -1, err
 		}
-	}
-
-	return count, nil
+		res = easysql.NewResult(r)
+		return res.RowsAffected, nil
+	})
+	return res, err
 }
 
-func (this *Conn) Close() error {
-	return nil
+//clickhouse has no generated ids
+func (this *Conn) InsertReturningID(cmd string, idColumn string, args ...interface{}) (int64, error) {
+	return 0, easysql.ErrNotSupported
 }
 
-func (item QItem) AsMap() map[string]interface{} {
-	return item
+//insert many records at one shot. often insert many logs.
+//values := make([]interface{}, 0, batchsize*nCol)
+//conn.BulkInsert("insert into logs(level,msg)", nCol, values...)
+func (this *Conn) BulkInsert(cmd string, nCol int, args ...interface{}) error {
+	return this.BulkInsertEx(cmd, nCol, args)
 }
 
-func (item QItem) GetColumnString(key string) string {
-	return item[key].(string)
-}
+func (this *Conn) BulkInsertEx(cmd string, nCol int, args []interface{}, szSQLsurfix ...string) error {
+	var szSQL string
+	szBracket := "(" + strings.TrimSuffix(strings.Repeat("?,", nCol), ",") + "),"
+	szSQL = cmd + " values " + szBracket
+	if len(szSQLsurfix) > 0 {
+		szSQL = szSQL + " " + strings.Join(szSQLsurfix, " ")
+	}
+
+	return this.run(easysql.OpBulkInsert, szSQL, args, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		tx, err := this.db.BeginTx(ctx, nil)
+		if err != nil {
+			return -1, err
+		}
+
+		stmt, err := tx.Prepare(query)
+		if err != nil {
+			return -1, err
+		}
+		defer stmt.Close()
 
-func (item QItem) GetColumnInt64(key string) int64 {
-	return item[key].(int64)
+		for i := 0; i < len(args); i += nCol {
+			if _, err := stmt.Exec(args[i : i+nCol]...); err != nil {
+				return -1, err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return -1, err
+		}
+		return int64(len(args) / nCol), nil
+	})
 }
 
-func (item QItem) GetColumnFloat64(key string) float64 {
-	return item[key].(float64)
+func MakeQArray() QArray {
+	return easysql.MakeQArray()
 }
 
-func (item QItem) ToInt64(keys ...string) QItem {
-	for _, key := range keys {
-		if ss, ok := item[key].(string); ok {
-			item[key], _ = strconv.ParseInt(ss, 10, 64)
-		}
+//query database
+func (this *Conn) Query(query string, args ...interface{}) (QArray, error) {
+	queryx, argsx, err := this.bind(query, args)
+	if err != nil {
+		return nil, err
 	}
-	return item
-}
 
-func (item QItem) IntToBool(keys ...string) QItem {
-	for _, key := range keys {
-		if ss, ok := item[key].(string); ok {
-			n, _ := strconv.ParseInt(ss, 10, 64)
-			if n == 0 {
-				item[key] = false
-			} else {
-				item[key] = true
+	var out QArray
+	err = this.run(easysql.OpQuery, queryx, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.read(ctx, func(q execAndQuery) (int64, error) {
+			rows, err := q.QueryxContext(ctx, query, args...)
+			if err != nil {
+				return -1, err
 			}
-		}
+			out, err = easysql.NewRows(rows).All()
+			return int64(len(out)), err
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
-	return item
+	return out, nil
 }
 
-func (item QItem) ToFloat64(keys ...string) QItem {
-	for _, key := range keys {
-		if ss, ok := item[key].(string); ok {
-			item[key], _ = strconv.ParseFloat(ss, 64)
-		}
+//query database without loading the whole result into memory.
+//the caller must close the returned rows.
+func (this *Conn) QueryRows(query string, args ...interface{}) (*easysql.Rows, error) {
+	queryx, argsx, err := this.bind(query, args)
+	if err != nil {
+		return nil, err
 	}
-	return item
-}
 
-func (item QItem) Base64Encode(keys ...string) QItem {
-	for _, key := range keys {
-		if ss, ok := item[key].(string); ok {
-			item[key] = base64.StdEncoding.EncodeToString([]byte(ss))
-		}
+	//the deadline covers reading the rows, it ends when they are closed
+	ctx, cancel := easysql.TimeoutContext(this.Context(), this.timeout)
+	var rows *sqlx.Rows
+	err = this.runContext(ctx, easysql.OpQuery, queryx, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.read(ctx, func(q execAndQuery) (int64, error) {
+			var err error
+			rows, err = q.QueryxContext(ctx, query, args...)
+			return -1, err
+		})
+	})
+	if err != nil {
+		cancel()
+		return nil, err
 	}
-	return item
+	return easysql.NewRowsWithCancel(rows, cancel), nil
 }
 
-func (array *QArray) ToInt64(key ...string) *QArray {
-	for _, item := range *array {
-		item.ToInt64(key...)
+//call fn for each row of the result, one row at a time.
+//conn.Iterate("select * from logs where day=?", []interface{}{day}, func(row QItem) error {...})
+func (this *Conn) Iterate(query string, args []interface{}, fn func(row QItem) error) error {
+	rows, err := this.QueryRows(query, args...)
+	if err != nil {
+		return err
 	}
-	return array
+	return rows.ForEach(fn)
 }
 
-func (array *QArray) Shuffle() *QArray {
-	nLen := len(*array)
-
-	if nLen < 2 {
-		return array
+//query database
+func (this *Conn) Select(dest interface{}, query string, args ...interface{}) error {
+	queryx, argsx, err := this.bind(query, args)
+	if err != nil {
+		return err
 	}
 
-	rand.Seed(time.Now().UnixNano())
-	dest := make([]QItem, nLen)
-	perm := rand.Perm(nLen)
-	for i, v := range perm {
-		dest[v] = (*array)[i]
-	}
+	n0 := easysql.DestLen(dest)
+	return this.run(easysql.OpSelect, queryx, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.read(ctx, func(q execAndQuery) (int64, error) {
+			easysql.TruncateDest(dest, n0) //sqlx appends, drop the rows of a failed attempt
+			if err := q.SelectContext(ctx, dest, query, args...); err != nil {
+				return -1, err
+			}
+			return easysql.DestLen(dest), nil
+		})
+	})
+}
 
-	*array = dest
+//query one row into a struct or a scalar.
+//returns easysql.ErrNoRows if the query matched no row.
+func (this *Conn) Get(dest interface{}, query string, args ...interface{}) error {
+	queryx, argsx, err := this.bind(query, args)
+	if err != nil {
+		return err
+	}
 
-	return array
+	return this.run(easysql.OpGet, queryx, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.read(ctx, func(q execAndQuery) (int64, error) {
+			err := q.GetContext(ctx, dest, query, args...)
+			if err == sql.ErrNoRows {
+				return 0, err
+			} else if err != nil {
+				return -1, err
+			}
+			return 1, nil
+		})
+	})
 }
 
-func (array *QArray) IntToBool(key ...string) *QArray {
-	for _, item := range *array {
-		item.IntToBool(key...)
+//named parameters, bound from a map[string]interface{} or a struct with db tags.
+//slices are expanded for IN clauses.
+//conn.ExecNamed("update accounts set age=:age where userid in (:ids)", map[string]interface{}{"age": 20, "ids": []int{1, 2}})
+func (this *Conn) ExecNamed(cmd string, arg interface{}) error {
+	query, args, err := sqlx.Named(cmd, arg)
+	if err != nil {
+		return err
 	}
-	return array
+	return this.Exec(query, args...)
 }
 
-func (array *QArray) ToFloat64(key ...string) *QArray {
-	for _, item := range *array {
-		item.ToFloat64(key...)
+func (this *Conn) QueryNamed(query string, arg interface{}) (QArray, error) {
+	queryx, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return nil, err
 	}
-	return array
+	return this.Query(queryx, args...)
 }
 
-func (array *QArray) Base64Encode(key ...string) *QArray {
-	for _, item := range *array {
-		item.Base64Encode(key...)
+func (this *Conn) SelectNamed(dest interface{}, query string, arg interface{}) error {
+	queryx, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return err
 	}
-	return array
+	return this.Select(dest, queryx, args...)
 }
 
-func (array *QArray) ToRawArray() []interface{} {
-	arr := make([]interface{}, 0, len(*array))
+//select count(*) from ...
+func (this *Conn) QueryCount(query string, args ...interface{}) (int64, error) {
+	queryx, argsx, err := this.bind(query, args)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64 = 0
+	err = this.run(easysql.OpCount, queryx, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.read(ctx, func(q execAndQuery) (int64, error) {
+			rows, err := q.QueryxContext(ctx, query, args...)
+			if err != nil {
+				return -1, err
+			}
+			defer rows.Close()
 
-	for _, item := range *array {
-		arr = append(arr, map[string]interface{}(item))
+			for rows.Next() {
+				if err := rows.Scan(&count); err != nil {
+					return -1, err
+				}
+			}
+			return 1, rows.Err()
+		})
+	})
+	if err != nil {
+		return 0, err
 	}
+	return count, nil
+}
 
-	return arr
+func (this *Conn) Close() error {
+	return nil
 }
@@ -0,0 +1,45 @@
+package clickhouse
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	clickhousedriver "github.com/ClickHouse/clickhouse-go"
+	"github.com/carr123/easysql"
+)
+
+// clickhouse exception code => SQLSTATE used by easysql
+var sqlStates = map[int32]string{
+	159: easysql.CodeQueryCanceled,      //TIMEOUT_EXCEEDED
+	394: easysql.CodeQueryCanceled,      //QUERY_WAS_CANCELLED
+	209: easysql.CodeConnectionFailure,  //SOCKET_TIMEOUT
+	210: easysql.CodeConnectionFailure,  //NETWORK_ERROR
+	202: easysql.CodeTooManyConnections, //TOO_MANY_SIMULTANEOUS_QUERIES
+}
+
+// wrap driver errors into *easysql.Error, other errors are returned as they are
+func wrapError(err error, query string) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := easysql.AsError(err); ok {
+		return err
+	}
+
+	var chErr *clickhousedriver.Exception
+	if errors.As(err, &chErr) {
+		return &easysql.Error{
+			Code:    sqlStates[chErr.Code],
+			Number:  int(chErr.Code),
+			Message: chErr.Message,
+			Query:   query,
+			Err:     err,
+		}
+	}
+
+	if errors.Is(err, driver.ErrBadConn) {
+		return &easysql.Error{Code: easysql.CodeConnectionFailure, Message: err.Error(), Query: query, Err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,32 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/carr123/easysql"
+	"github.com/jmoiron/sqlx"
+)
+
+//ping the server and report its version, replication lag and pool usage.
+//the lag is the largest absolute_delay of the replicated tables.
+func (this *DBServer) Health(ctx context.Context) easysql.Health {
+	probe := easysql.HealthProbe{Backend: backendName, Version: version, ReplicationLag: replicationLag}
+	return easysql.CheckHealth(ctx, this.db, this.replicas, probe, this.opts.HealthThresholds)
+}
+
+func version(ctx context.Context, db *sqlx.DB) (string, error) {
+	var v string
+	err := db.GetContext(ctx, &v, "SELECT version()")
+	return v, err
+}
+
+func replicationLag(ctx context.Context, db *sqlx.DB) (time.Duration, bool, error) {
+	var seconds sql.NullInt64
+	err := db.GetContext(ctx, &seconds, "SELECT max(absolute_delay) FROM system.replicas")
+	if err != nil || !seconds.Valid {
+		return 0, false, err
+	}
+	return time.Duration(seconds.Int64) * time.Second, true, nil
+}
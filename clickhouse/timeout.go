@@ -0,0 +1,56 @@
+package clickhouse
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//add "SETTINGS max_execution_time" to a SELECT when the conn timeout differs from the
+//DBServer default, which is already set on every connection by the DSN, see withMaxExecutionTime.
+func (this *Conn) maxExecutionTime(query string) string {
+	if this.timeout <= 0 || this.timeout == this.srv.opts.QueryTimeout {
+		return query
+	}
+
+	trimmed := strings.TrimSpace(query)
+	upper := strings.ToUpper(trimmed)
+	if !strings.HasPrefix(upper, "SELECT") && !strings.HasPrefix(upper, "WITH") {
+		return query
+	}
+	//an existing SETTINGS or FORMAT clause can't be combined safely
+	if strings.Contains(upper, "SETTINGS") || strings.Contains(upper, "FORMAT") {
+		return query
+	}
+
+	trimmed = strings.TrimRight(trimmed, "; \t\r\n")
+	return trimmed + " SETTINGS max_execution_time=" + strconv.FormatInt(seconds(this.timeout), 10)
+}
+
+//"tcp://127.0.0.1:9000?debug=true" => "tcp://127.0.0.1:9000?debug=true&max_execution_time=5"
+func withMaxExecutionTime(dataSourceName string, d time.Duration) (string, error) {
+	if d <= 0 {
+		return dataSourceName, nil
+	}
+
+	u, err := url.Parse(dataSourceName)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	if query.Get("max_execution_time") == "" {
+		query.Set("max_execution_time", strconv.FormatInt(seconds(d), 10))
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+//max_execution_time is in seconds
+func seconds(d time.Duration) int64 {
+	s := int64((d + time.Second - 1) / time.Second)
+	if s < 1 {
+		s = 1
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package clickhouse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/carr123/easysql"
+)
+
+func TestMaxExecutionTime(t *testing.T) {
+	srv := &DBServer{opts: &easysql.Options{QueryTimeout: 5 * time.Second}}
+
+	tests := []struct {
+		query   string
+		timeout time.Duration
+		want    string
+	}{
+		{"select * from t", 2 * time.Second, "select * from t SETTINGS max_execution_time=2"},
+		{"SELECT 1;", 1500 * time.Millisecond, "SELECT 1 SETTINGS max_execution_time=2"},
+		{"with c as (select 1) select * from c", time.Second, "with c as (select 1) select * from c SETTINGS max_execution_time=1"},
+		{"select 1", time.Millisecond, "select 1 SETTINGS max_execution_time=1"},
+
+		//the default is set by the DSN
+		{"select 1", 5 * time.Second, "select 1"},
+		{"select 1", 0, "select 1"},
+		{"insert into t values (1)", time.Second, "insert into t values (1)"},
+		{"select 1 settings max_threads=1", time.Second, "select 1 settings max_threads=1"},
+		{"select 1 format JSON", time.Second, "select 1 format JSON"},
+	}
+
+	for _, tt := range tests {
+		conn := &Conn{srv: srv, timeout: tt.timeout}
+		if got := conn.maxExecutionTime(tt.query); got != tt.want {
+			t.Errorf("maxExecutionTime(%q) with timeout %v = %q, want %q", tt.query, tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestWithMaxExecutionTime(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		d    time.Duration
+		want string
+	}{
+		{"tcp://127.0.0.1:9000?debug=true", 5 * time.Second, "tcp://127.0.0.1:9000?debug=true&max_execution_time=5"},
+		{"tcp://127.0.0.1:9000", 1500 * time.Millisecond, "tcp://127.0.0.1:9000?max_execution_time=2"},
+		{"tcp://127.0.0.1:9000?max_execution_time=60", time.Second, "tcp://127.0.0.1:9000?max_execution_time=60"},
+		{"tcp://127.0.0.1:9000", 0, "tcp://127.0.0.1:9000"},
+	}
+
+	for _, tt := range tests {
+		got, err := withMaxExecutionTime(tt.dsn, tt.d)
+		if err != nil {
+			t.Errorf("withMaxExecutionTime(%q, %v): %v", tt.dsn, tt.d, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("withMaxExecutionTime(%q, %v) = %q, want %q", tt.dsn, tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/carr123/easysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// how often the nodes of a cluster are pinged
+var NodeCheckInterval = 5 * time.Second
+
+// NewCluster connects to several nodes of one cluster, one DSN per node:
+//
+//	cockroach.NewCluster([]string{
+//		"postgresql://root@10.0.0.1:26257/bank?sslmode=disable",
+//		"postgresql://root@10.0.0.2:26257/bank?sslmode=disable",
+//	})
+//
+// new connections are spread round-robin over the healthy nodes. nodes failing a ping
+// or a connection are skipped until they answer again, and their pooled connections are dropped.
+// reads outside transactions failing with a connection error are retried on another node.
+func NewCluster(nodes []string, opts ...easysql.Option) (*DBServer, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("easysql: no cockroach nodes")
+	}
+
+	opts = append([]easysql.Option{easysql.WithConnMaxLifetime(time.Second * 300), easysql.WithRetryPolicy(DefaultRetryPolicy())}, opts...)
+	o := easysql.NewOptions(opts...)
+	o.AddConvertDSN(func(dsn string) (string, error) {
+		return withStatementTimeout(dsn, o.QueryTimeout)
+	})
+
+	c := &cluster{stop: make(chan struct{})}
+	for _, dsn := range nodes {
+		dsn, err := withStatementTimeout(dsn, o.QueryTimeout)
+		if err != nil {
+			return nil, err
+		}
+		connector, err := pq.NewConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		c.nodes = append(c.nodes, &node{dsn: dsn, connector: connector})
+	}
+
+	db, err := easysql.ConnectDB(sqlx.NewDb(sql.OpenDB(c), "postgres"), o)
+	if err != nil {
+		return nil, err
+	}
+
+	inst, err := newServer(db, o)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	c.wg.Add(1)
+	go c.healthLoop()
+	inst.cluster = c
+	return inst, nil
+}
+
+type node struct {
+	dsn       string
+	connector driver.Connector
+	down      int32
+}
+
+// cluster is the driver.Connector of a multi-node DBServer
+type cluster struct {
+	nodes []*node
+	next  uint32
+	stop  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+func (c *cluster) Connect(ctx context.Context) (driver.Conn, error) {
+	start := int(atomic.AddUint32(&c.next, 1))
+	n := len(c.nodes)
+
+	var lastErr error
+	//healthy nodes first, then the others in case the health check is behind
+	for _, wantDown := range []int32{0, 1} {
+		for i := 0; i < n; i++ {
+			nd := c.nodes[(start+i)%n]
+			if atomic.LoadInt32(&nd.down) != wantDown {
+				continue
+			}
+
+			conn, err := nd.connector.Connect(ctx)
+			if err == nil {
+				atomic.StoreInt32(&nd.down, 0)
+				return &nodeConn{Conn: conn, node: nd}, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			c.markDown(nd, err)
+		}
+	}
+	return nil, lastErr
+}
+
+func (c *cluster) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+func (c *cluster) size() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.nodes)
+}
+
+func (c *cluster) markDown(nd *node, err error) {
+	if atomic.SwapInt32(&nd.down, 1) == 0 {
+		easysql.StdLogger.Warn("easysql: cockroach node is down", "node", redactDSN(nd.dsn), "error", err)
+	}
+}
+
+func (c *cluster) healthLoop() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(NodeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			for _, nd := range c.nodes {
+				c.check(nd)
+			}
+		}
+	}
+}
+
+// ping a node on a fresh connection, pooled ones may go to another node
+func (c *cluster) check(nd *node) {
+	ctx, cancel := context.WithTimeout(context.Background(), NodeCheckInterval)
+	defer cancel()
+
+	conn, err := nd.connector.Connect(ctx)
+	if err == nil {
+		err = conn.(driver.Pinger).Ping(ctx)
+		conn.Close()
+	}
+	if err != nil {
+		c.markDown(nd, err)
+		return
+	}
+	atomic.StoreInt32(&nd.down, 0)
+}
+
+func (c *cluster) Close() {
+	if c == nil {
+		return
+	}
+	c.once.Do(func() {
+		close(c.stop)
+	})
+	c.wg.Wait()
+}
+
+// nodeConn marks its node down on connection errors.
+// connections of a down node are reported invalid, so the pool drops them.
+// a connection broken by a canceled statement or reported bad by lib/pq is dropped alone,
+// its node is fine.
+type nodeConn struct {
+	driver.Conn
+	node   *node
+	broken int32
+}
+
+var (
+	_ driver.QueryerContext     = (*nodeConn)(nil)
+	_ driver.ExecerContext      = (*nodeConn)(nil)
+	_ driver.ConnPrepareContext = (*nodeConn)(nil)
+	_ driver.ConnBeginTx        = (*nodeConn)(nil)
+	_ driver.Pinger             = (*nodeConn)(nil)
+	_ driver.Validator          = (*nodeConn)(nil)
+)
+
+func (c *nodeConn) observe(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// lib/pq marks a connection bad after a timeout or cancel, and its next use returns
+	// driver.ErrBadConn: a problem of this connection only
+	if ctx.Err() != nil || easysql.IsTimeout(err) || errors.Is(err, driver.ErrBadConn) {
+		atomic.StoreInt32(&c.broken, 1)
+		return err
+	}
+
+	if easysql.IsConnectionError(err) {
+		atomic.StoreInt32(&c.broken, 1)
+		atomic.StoreInt32(&c.node.down, 1)
+	}
+	return err
+}
+
+func (c *nodeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	return rows, c.observe(ctx, err)
+}
+
+func (c *nodeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+	return res, c.observe(ctx, err)
+}
+
+func (c *nodeConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+	return stmt, c.observe(ctx, err)
+}
+
+func (c *nodeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	return tx, c.observe(ctx, err)
+}
+
+func (c *nodeConn) Ping(ctx context.Context) error {
+	return c.observe(ctx, c.Conn.(driver.Pinger).Ping(ctx))
+}
+
+func (c *nodeConn) IsValid() bool {
+	return atomic.LoadInt32(&c.broken) == 0 && atomic.LoadInt32(&c.node.down) == 0
+}
@@ -0,0 +1,217 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/carr123/easysql"
+	"github.com/lib/pq"
+)
+
+type fakeConnector struct {
+	err      error
+	connects int
+}
+
+func (f *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	f.connects++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &fakeConn{}, nil
+}
+
+func (f *fakeConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// fakeConn fails every statement with err
+type fakeConn struct {
+	err error
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, c.err }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, c.err }
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return nil, c.err
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), c.err
+}
+
+func (c *fakeConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return nil, c.err
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return nil, c.err
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error { return c.err }
+
+type discardLogger struct{}
+
+func (discardLogger) Info(msg string, args ...interface{})  {}
+func (discardLogger) Warn(msg string, args ...interface{})  {}
+func (discardLogger) Error(msg string, args ...interface{}) {}
+
+func newTestCluster(t *testing.T, connectors ...*fakeConnector) *cluster {
+	logger := easysql.StdLogger
+	easysql.StdLogger = discardLogger{}
+	t.Cleanup(func() { easysql.StdLogger = logger })
+
+	c := &cluster{stop: make(chan struct{})}
+	for _, connector := range connectors {
+		c.nodes = append(c.nodes, &node{dsn: "postgresql://root@node:26257/bank", connector: connector})
+	}
+	return c
+}
+
+func connectedNode(t *testing.T, c *cluster) *node {
+	conn, err := c.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn.(*nodeConn).node
+}
+
+func TestClusterRoundRobin(t *testing.T) {
+	c := newTestCluster(t, &fakeConnector{}, &fakeConnector{}, &fakeConnector{})
+
+	seen := map[*node]int{}
+	for i := 0; i < 6; i++ {
+		seen[connectedNode(t, c)]++
+	}
+	for i, nd := range c.nodes {
+		if seen[nd] != 2 {
+			t.Errorf("node %d got %d connections, want 2", i, seen[nd])
+		}
+	}
+}
+
+func TestClusterSkipsDownNodes(t *testing.T) {
+	c := newTestCluster(t, &fakeConnector{}, &fakeConnector{}, &fakeConnector{})
+	c.nodes[1].down = 1
+
+	for i := 0; i < 6; i++ {
+		if connectedNode(t, c) == c.nodes[1] {
+			t.Fatal("connected to a down node while others are healthy")
+		}
+	}
+	if n := c.nodes[1].connector.(*fakeConnector).connects; n != 0 {
+		t.Errorf("down node was dialed %d times", n)
+	}
+}
+
+func TestClusterFailover(t *testing.T) {
+	refused := errors.New("connection refused")
+	c := newTestCluster(t, &fakeConnector{err: refused}, &fakeConnector{})
+
+	for i := 0; i < 4; i++ {
+		if connectedNode(t, c) != c.nodes[1] {
+			t.Fatal("connected to the failing node")
+		}
+	}
+	if c.nodes[0].down != 1 {
+		t.Error("failing node was not marked down")
+	}
+}
+
+func TestClusterRetriesDownNodes(t *testing.T) {
+	// the health check may be behind: a node marked down is still tried when no other is left
+	c := newTestCluster(t, &fakeConnector{}, &fakeConnector{})
+	c.nodes[0].down = 1
+	c.nodes[1].down = 1
+
+	connectedNode(t, c)
+	if c.nodes[0].down+c.nodes[1].down != 1 {
+		t.Error("the reachable node was not marked up again")
+	}
+}
+
+func TestClusterAllDown(t *testing.T) {
+	last := errors.New("refused")
+	c := newTestCluster(t, &fakeConnector{err: last}, &fakeConnector{err: last})
+
+	if _, err := c.Connect(context.Background()); err != last {
+		t.Errorf("err = %v, want %v", err, last)
+	}
+}
+
+func TestNodeConnObserve(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		err        error
+		broken     bool
+		nodeIsDown bool
+	}{
+		{"success", context.Background(), nil, false, false},
+		{"statement error", context.Background(), &pq.Error{Code: "23505"}, false, false},
+		{"context ended", canceled, io.EOF, true, false},
+		{"statement timeout", context.Background(), &pq.Error{Code: "57014"}, true, false},
+		{"deadline", context.Background(), context.DeadlineExceeded, true, false},
+		// the next use of a connection lib/pq marked bad after a timeout
+		{"bad conn", context.Background(), driver.ErrBadConn, true, false},
+		{"eof", context.Background(), io.EOF, true, true},
+		{"connection failure", context.Background(), &pq.Error{Code: "08006"}, true, true},
+	}
+
+	for _, tt := range tests {
+		nd := &node{}
+		conn := &nodeConn{Conn: &fakeConn{err: tt.err}, node: nd}
+
+		_, err := conn.ExecContext(tt.ctx, "select 1", nil)
+		if err != tt.err {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+		if got := conn.broken == 1; got != tt.broken {
+			t.Errorf("%s: broken = %v, want %v", tt.name, got, tt.broken)
+		}
+		if got := nd.down == 1; got != tt.nodeIsDown {
+			t.Errorf("%s: node down = %v, want %v", tt.name, got, tt.nodeIsDown)
+		}
+		if conn.IsValid() != !tt.broken {
+			t.Errorf("%s: IsValid = %v", tt.name, conn.IsValid())
+		}
+	}
+}
+
+func TestNodeConnInvalidWhenNodeDown(t *testing.T) {
+	nd := &node{}
+	a := &nodeConn{Conn: &fakeConn{}, node: nd}
+	b := &nodeConn{Conn: &fakeConn{err: io.EOF}, node: nd}
+
+	b.QueryContext(context.Background(), "select 1", nil)
+	if a.IsValid() {
+		t.Error("connection of a down node is still valid")
+	}
+}
+
+func TestClusterClose(t *testing.T) {
+	c := newTestCluster(t, &fakeConnector{})
+	c.wg.Add(1)
+	go c.healthLoop()
+
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		c.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the health loop")
+	}
+}
@@ -3,37 +3,57 @@ package cockroach
 import (
 	"context"
 	"database/sql"
-	"encoding/base64"
-	"math/rand"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/cockroachdb/cockroach-go/v2/crdb"
+	"github.com/carr123/easysql"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
 )
 
 type DBServer struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	opts     *easysql.Options
+	hooks    *easysql.Hooks
+	replicas *easysql.ReplicaSet //nil without replicas
+	cluster  *cluster            //nil unless created by NewCluster
 }
 
 type Conn struct {
-	db     *sqlx.DB
-	tx     *sqlx.Tx
-	excter execAndQuery
-	ctx    context.Context
-}
-
-type QItem map[string]interface{}
-type QArray []QItem
+	srv       *DBServer
+	db        *sqlx.DB
+	tx        *sqlx.Tx
+	excter    execAndQuery
+	ctx       context.Context
+	depth     int                  //savepoint nesting level inside tx
+	callbacks *easysql.TxCallbacks //nil outside a transaction
+	primary   bool                 //reads skip the replicas
+	timeout   time.Duration        //statement timeout, 0 means none
+	txTimeout *time.Duration       //statement_timeout in effect in tx, shared by its conns
+}
+
+// QueryEvent.Backend
+const backendName = "cockroachdb"
+
+type QItem = easysql.QItem
+type QArray = easysql.QArray
+
+var (
+	_ easysql.DB   = (*DBServer)(nil)
+	_ easysql.Conn = (*Conn)(nil)
+)
 
 type execAndQuery interface {
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
 	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 }
 
+// TxCompatible adapted *sqlx.Tx to crdb.ExecuteInTx, which ExecInTx no longer uses.
+//
+// Deprecated: ExecInTx retries transactions itself, see easysql.RetryPolicy.
 type TxCompatible struct {
 	*sqlx.Tx
 }
@@ -53,14 +73,44 @@ func (t *TxCompatible) Rollback(context.Context) error {
 
 // "postgresql://root@127.0.0.1:26257/bank?sslmode=disable"
 func New(dataSourceName string, MaxIdleConn int) (*DBServer, error) {
-	inst := &DBServer{}
-	db, err := sqlx.Connect("postgres", dataSourceName)
+	return NewWithOptions(dataSourceName, easysql.WithMaxIdleConns(MaxIdleConn))
+}
+
+func NewWithOptions(dataSourceName string, opts ...easysql.Option) (*DBServer, error) {
+	opts = append([]easysql.Option{easysql.WithConnMaxLifetime(time.Second * 300), easysql.WithRetryPolicy(DefaultRetryPolicy())}, opts...)
+	o := easysql.NewOptions(opts...)
+	o.AddConvertDSN(func(dsn string) (string, error) {
+		return withStatementTimeout(dsn, o.QueryTimeout)
+	})
+	dsn, err := withStatementTimeout(dataSourceName, o.QueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := easysql.Connect("postgres", dsn, o)
+	if err != nil {
+		return nil, err
+	}
+
+	inst, err := newServer(db, o)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return inst, nil
+}
+
+func newServer(db *sqlx.DB, o *easysql.Options) (*DBServer, error) {
+	replicas, err := easysql.NewReplicaSet("postgres", backendName, o)
 	if err != nil {
 		return nil, err
 	}
-	db.SetMaxIdleConns(MaxIdleConn)
-	db.SetConnMaxLifetime(time.Second * 300)
+
+	inst := &DBServer{}
 	inst.db = db
+	inst.replicas = replicas
+	inst.opts = o
+	inst.hooks = easysql.NewHooks(o.ServerHooks(backendName, db, replicas)...)
 	return inst, nil
 }
 
@@ -69,29 +119,138 @@ func (this *DBServer) Close() {
 		this.db.Close()
 		this.db = nil
 	}
+	this.replicas.Close()
+	this.replicas = nil
+	this.cluster.Close()
+	this.cluster = nil
+}
+
+// add hooks called around every statement and transaction, see easysql.Hook.
+// hooks are meant to be added at startup, before the DBServer is shared.
+func (this *DBServer) Use(hooks ...easysql.Hook) {
+	this.hooks.Use(hooks...)
 }
 
-func (this *DBServer) NewConn() *Conn {
-	return &Conn{db: this.db, tx: nil, excter: this.db, ctx: context.Background()}
+// statistics of the connection pool, see also easysql.WithMetrics
+func (this *DBServer) Stats() sql.DBStats {
+	return this.db.Stats()
 }
 
-func (this *DBServer) ExecInTx(fn func(*Conn) error) error {
+func (this *DBServer) NewConn() easysql.Conn {
+	return &Conn{srv: this, db: this.db, tx: nil, excter: this.db, ctx: context.Background(), timeout: this.opts.QueryTimeout}
+}
+
+func (this *DBServer) ExecInTx(fn func(easysql.Conn) error) error {
 	ctx := context.Background()
 	return this.ExecInTxContext(ctx, fn)
 }
 
-func (this *DBServer) ExecInTxContext(ctx context.Context, fn func(*Conn) error) error {
-	tx, err := this.db.BeginTxx(ctx, nil)
+func (this *DBServer) ExecInTxContext(ctx context.Context, fn func(easysql.Conn) error) error {
+	return this.ExecInTxWithOptions(ctx, nil, fn)
+}
+
+// Isolation, ReadOnly, AsOfSystemTime and Priority are supported.
+// db.ExecInTxWithOptions(ctx, &easysql.TxOptions{AsOfSystemTime: "follower_read_timestamp()"}, fn)
+func (this *DBServer) ExecInTxWithOptions(ctx context.Context, opts *easysql.TxOptions, fn func(easysql.Conn) error) error {
+	var callbacks *easysql.TxCallbacks
+	err := this.opts.RetryPolicy.Do(ctx, isRetryable, func() error {
+		callbacks = easysql.NewTxCallbacks()
+		ev := &easysql.QueryEvent{Op: easysql.OpTx, Backend: backendName, InTx: true}
+		return this.hooks.Run(ctx, ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+			return -1, this.execInTx(ctx, opts, callbacks, fn)
+		})
+	})
+	callbacks.Fire(err)
+	return err
+}
+
+func (this *DBServer) execInTx(ctx context.Context, opts *easysql.TxOptions, callbacks *easysql.TxCallbacks, fn func(easysql.Conn) error) error {
+	tx, err := this.begin(ctx, opts)
 	if err != nil {
 		return err
 	}
 
-	conn := &Conn{db: this.db, tx: tx, excter: tx, ctx: ctx}
-	err = crdb.ExecuteInTx(ctx, &TxCompatible{tx}, func() error {
-		return fn(conn)
+	conn := &Conn{srv: this, db: this.db, tx: tx, excter: tx, ctx: ctx, callbacks: callbacks, timeout: this.opts.QueryTimeout, txTimeout: this.txTimeout()}
+	if err := fn(conn); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return wrapError(tx.Commit(), "COMMIT")
+}
+
+func (this *DBServer) begin(ctx context.Context, opts *easysql.TxOptions) (*sqlx.Tx, error) {
+	tx, err := this.db.BeginTxx(ctx, opts.SQLOptions())
+	if err != nil {
+		return nil, wrapError(err, "BEGIN")
+	}
+
+	if err := setTxOptions(ctx, tx, opts); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// manual transaction for long running work, e.g. committing every N records.
+// the caller must call Commit or Rollback, and should defer tx.Close() as a safety net.
+// unlike ExecInTx, failed transactions are not retried.
+func (this *DBServer) Begin(ctx context.Context, opts *easysql.TxOptions) (*easysql.Tx, error) {
+	var tx *sqlx.Tx
+	ev := &easysql.QueryEvent{Op: easysql.OpBegin, Backend: backendName, Query: "BEGIN", InTx: true}
+	err := this.hooks.Run(ctx, ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		var err error
+		tx, err = this.begin(ctx, opts)
+		return -1, err
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	callbacks := easysql.NewTxCallbacks()
+	conn := &Conn{srv: this, db: this.db, tx: tx, excter: tx, ctx: ctx, callbacks: callbacks, timeout: this.opts.QueryTimeout, txTimeout: this.txTimeout()}
+	commit := func() error {
+		return this.endTx(ctx, easysql.OpCommit, "COMMIT", tx.Commit)
+	}
+	rollback := func() error {
+		return this.endTx(ctx, easysql.OpRollback, "ROLLBACK", tx.Rollback)
+	}
+	return easysql.NewTx(conn, callbacks, commit, rollback), nil
+}
+
+func (this *DBServer) endTx(ctx context.Context, op string, query string, fn func() error) error {
+	ev := &easysql.QueryEvent{Op: op, Backend: backendName, Query: query, InTx: true}
+	return this.hooks.Run(ctx, ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return -1, wrapError(fn(), query)
+	})
+}
+
+func setTxOptions(ctx context.Context, tx *sqlx.Tx, opts *easysql.TxOptions) error {
+	if opts == nil {
+		return nil
+	}
+
+	if opts.AsOfSystemTime != "" {
+		if _, err := tx.ExecContext(ctx, "SET TRANSACTION AS OF SYSTEM TIME "+opts.AsOfSystemTime); err != nil {
+			return err
+		}
+	}
+
+	if opts.Priority != "" {
+		priority := strings.ToUpper(opts.Priority)
+		if priority != "LOW" && priority != "NORMAL" && priority != "HIGH" {
+			return fmt.Errorf("easysql: invalid transaction priority %q", opts.Priority)
+		}
+		if _, err := tx.ExecContext(ctx, "SET TRANSACTION PRIORITY "+priority); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SQLSTATE 40001 (restart transaction) and 40P01 (deadlock)
+func isRetryable(err error) bool {
+	return easysql.IsSerializationFailure(err) || easysql.IsDeadlock(err)
 }
 
 func (this *Conn) Context() context.Context {
@@ -101,29 +260,227 @@ func (this *Conn) Context() context.Context {
 	return context.Background()
 }
 
-func (this *Conn) WithContext(ctx context.Context) *Conn {
+func (this *Conn) WithContext(ctx context.Context) easysql.Conn {
 	if ctx == nil {
 		panic("nil context")
 	}
-	conn2 := &Conn{db: this.db, tx: this.tx, excter: this.excter, ctx: ctx}
-	return conn2
+	conn2 := *this
+	conn2.ctx = ctx
+	return &conn2
+}
+
+// reads of the returned conn go to the primary, to read your own writes when replicas are configured.
+func (this *Conn) ForcePrimary() easysql.Conn {
+	conn2 := *this
+	conn2.primary = true
+	return &conn2
+}
+
+// statements of the returned conn time out after d, 0 means no timeout.
+// d is also set as statement_timeout on the server: with SET LOCAL in a transaction, and
+// outside transactions on a dedicated connection, one extra round trip before and after.
+// conn.WithTimeout(time.Second).Query("select ...")
+func (this *Conn) WithTimeout(d time.Duration) easysql.Conn {
+	conn2 := *this
+	conn2.timeout = d
+	return &conn2
+}
+
+// reads outside a transaction go to a replica, see easysql.WithReplicas.
+// after a connection error on the replica, the read is retried on the primary,
+// and on another node of a cluster, see NewCluster.
+// run fn on q with the timeout of the conn, see limit.
+// rows read by fn must be closed before it returns.
+func (this *Conn) read(ctx context.Context, fn func(q execAndQuery) (int64, error)) (int64, error) {
+	return this.route(ctx, func(q execAndQuery) (int64, error) {
+		return this.limit(ctx, q, fn)
+	})
+}
+
+func (this *Conn) route(ctx context.Context, fn func(q execAndQuery) (int64, error)) (int64, error) {
+	if this.tx != nil {
+		return fn(this.excter)
+	}
+
+	if !this.primary {
+		if replica := this.srv.replicas.Reader(); replica != nil {
+			n, err := fn(replica)
+			if err == nil || !retryRead(ctx, err) {
+				return n, err
+			}
+			this.srv.replicas.MarkDown(replica)
+		}
+	}
+
+	// on a cluster the retries get connections to other nodes
+	n, err := fn(this.excter)
+	for i := 0; i < this.srv.cluster.size() && err != nil && retryRead(ctx, err); i++ {
+		n, err = fn(this.excter)
+	}
+	return n, err
+}
+
+// a failed read is retried elsewhere after a connection error only,
+// never once the statement context has ended or after a timeout.
+func retryRead(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	err = wrapError(err, "")
+	return easysql.IsConnectionError(err) && !easysql.IsTimeout(err)
+}
+
+// nested transaction.
+// outside a transaction it starts one like DBServer.ExecInTx.
+// inside a transaction fn runs within a SAVEPOINT, which is rolled back if fn returns an error
+// and released otherwise, so the outer transaction can still commit.
+func (this *Conn) ExecInTx(fn func(easysql.Conn) error) error {
+	if this.tx == nil {
+		return this.srv.ExecInTxContext(this.Context(), fn)
+	}
+
+	conn := *this
+	conn.depth = this.depth + 1
+	conn.callbacks = this.callbacks.Savepoint()
+	name := "easysql_sp" + strconv.Itoa(conn.depth)
+	err := easysql.RunInSavepoint(name, this.savepoint, func() error {
+		return fn(&conn)
+	})
+	if err != nil {
+		// a SET LOCAL inside the savepoint may have been rolled back with it
+		*this.txTimeout = unknownTimeout
+	}
+	conn.callbacks.Fire(err)
+	return err
+}
+
+// SAVEPOINT, ROLLBACK TO and RELEASE of ExecInTx, run like Exec but without setting
+// statement_timeout first: after a failed statement the transaction only accepts ROLLBACK TO.
+func (this *Conn) savepoint(cmd string) error {
+	return this.run(easysql.OpSavepoint, cmd, nil, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		_, err := this.tx.ExecContext(ctx, query, args...)
+		return -1, err
+	})
+}
+
+// run fn once the transaction of this conn has committed.
+// outside a transaction fn runs immediately.
+// if the transaction is retried, only the callbacks of the final attempt run.
+func (this *Conn) AfterCommit(fn func()) {
+	if this.callbacks == nil {
+		fn()
+		return
+	}
+	this.callbacks.AfterCommit(fn)
+}
+
+// run fn once the transaction of this conn (or its savepoint) has rolled back.
+// err is the reason, nil after an explicit Tx.Rollback. outside a transaction fn never runs.
+func (this *Conn) AfterRollback(fn func(err error)) {
+	if this.callbacks == nil {
+		return
+	}
+	this.callbacks.AfterRollback(fn)
 }
 
 func (this *Conn) Ping() error {
-	return this.db.Ping()
+	return wrapError(this.db.PingContext(this.Context()), "")
+}
+
+// expand slices for IN clauses and rebind the placeholders
+func (this *Conn) bind(query string, args []interface{}) (string, []interface{}, error) {
+	queryx, argsx, err := sqlx.In(query, args...)
+	if err != nil {
+		return "", nil, err
+	}
+	return this.db.Rebind(queryx), argsx, nil
+}
+
+// run fn between the hooks. fn gets the query and args as modified by the hooks,
+// and the context to pass to the driver.
+func (this *Conn) run(op string, query string, args []interface{}, fn func(ctx context.Context, query string, args []interface{}) (int64, error)) error {
+	ctx, cancel := easysql.TimeoutContext(this.Context(), this.timeout)
+	defer cancel()
+	return this.runContext(ctx, op, query, args, fn)
+}
+
+// run with a context whose lifetime is managed by the caller
+func (this *Conn) runContext(ctx context.Context, op string, query string, args []interface{}, fn func(ctx context.Context, query string, args []interface{}) (int64, error)) error {
+	ev := &easysql.QueryEvent{Op: op, Backend: backendName, Query: query, Args: args, InTx: this.tx != nil}
+	return this.srv.hooks.Run(ctx, ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		if op != easysql.OpSavepoint {
+			if err := this.setStatementTimeout(ctx); err != nil {
+				return -1, wrapError(err, query)
+			}
+		}
+		n, err := fn(ctx, query, args)
+		return n, wrapError(err, query)
+	})
 }
 
 // insert update delete
 // create table, alter index etc.
 func (this *Conn) Exec(cmd string, args ...interface{}) error {
-	query, argsx, err := sqlx.In(cmd, args...)
+	_, err := this.ExecResult(cmd, args...)
+	return err
+}
+
+// same as Exec, also reports the number of affected rows
+func (this *Conn) ExecResult(cmd string, args ...interface{}) (easysql.Result, error) {
+	return this.execResult(easysql.OpExec, cmd, args)
+}
+
+func (this *Conn) execResult(op string, cmd string, args []interface{}) (easysql.Result, error) {
+	query, argsx, err := this.bind(cmd, args)
 	if err != nil {
-		return err
+		return easysql.Result{}, err
 	}
 
-	query = this.db.Rebind(query)
-	_, err = this.excter.ExecContext(this.Context(), query, argsx...)
-	return err
+	var res easysql.Result
+	err = this.run(op, query, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.limit(ctx, this.excter, func(q execAndQuery) (int64, error) {
+			r, err := q.ExecContext(ctx, query, args...)
+			if err != nil {
+				return -1, err
+			}
+			res = easysql.NewResult(r)
+			return res.RowsAffected, nil
+		})
+	})
+	return res, err
+}
+
+// insert one record and return the value of idColumn, using "RETURNING idColumn".
+// id, err := conn.InsertReturningID("insert into accounts(username)values(?)", "id", "user5")
+func (this *Conn) InsertReturningID(cmd string, idColumn string, args ...interface{}) (int64, error) {
+	queryx, argsx, err := this.bind(cmd+" RETURNING "+idColumn, args)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = this.run(easysql.OpExec, queryx, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.limit(ctx, this.excter, func(q execAndQuery) (int64, error) {
+			rows, err := q.QueryxContext(ctx, query, args...)
+			if err != nil {
+				return -1, err
+			}
+			defer rows.Close()
+
+			if !rows.Next() {
+				if err := rows.Err(); err != nil {
+					return -1, err
+				}
+				return 0, easysql.ErrNoRows
+			}
+
+			if err := rows.Scan(&id); err != nil {
+				return -1, err
+			}
+			return 1, rows.Err()
+		})
+	})
+	return id, err
 }
 
 // insert many records at one shot. often insert many logs.
@@ -133,7 +490,8 @@ func (this *Conn) BulkInsert(cmd string, nCol int, args ...interface{}) error {
 	var szSQL string
 	szBracket := "(" + strings.TrimSuffix(strings.Repeat("?,", nCol), ",") + "),"
 	szSQL = cmd + " values " + strings.TrimSuffix(strings.Repeat(szBracket, len(args)/nCol), ",")
-	return this.Exec(szSQL, args...)
+	_, err := this.execResult(easysql.OpBulkInsert, szSQL, args)
+	return err
 }
 
 // conn.BulkInsertEx("insert into msgs(fid, username, area)", nColumn, values, "ON CONFLICT(fid) DO NOTHING")
@@ -148,189 +506,182 @@ func (this *Conn) BulkInsertEx(cmd string, nCol int, args []interface{}, szSQLsu
 		szSQL = szSQL + " " + strings.Join(szSQLsurfix, " ")
 	}
 
-	return this.Exec(szSQL, args...)
+	_, err := this.execResult(easysql.OpBulkInsert, szSQL, args)
+	return err
 }
 
 func MakeQArray() QArray {
-	return make(QArray, 0)
+	return easysql.MakeQArray()
 }
 
 // query database
 func (this *Conn) Query(query string, args ...interface{}) (QArray, error) {
-	queryx, argsx, err := sqlx.In(query, args...)
+	queryx, argsx, err := this.bind(query, args)
 	if err != nil {
 		return nil, err
 	}
-	queryx = this.db.Rebind(queryx)
 
-	rows, err := this.excter.QueryxContext(this.Context(), queryx, argsx...)
+	var out QArray
+	err = this.run(easysql.OpQuery, queryx, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.read(ctx, func(q execAndQuery) (int64, error) {
+			rows, err := q.QueryxContext(ctx, query, args...)
+			if err != nil {
+				return -1, err
+			}
+			out, err = easysql.NewRows(rows).All()
+			return int64(len(out)), err
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	result := make(QArray, 0, 100)
-	for rows.Next() {
-		out := make(map[string]interface{})
-		err := rows.MapScan(out)
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, out)
-	}
-
-	return result, nil
+	return out, nil
 }
 
-// query database
-func (this *Conn) Select(dest interface{}, query string, args ...interface{}) error {
-	queryx, argsx, err := sqlx.In(query, args...)
+// query database without loading the whole result into memory.
+// the caller must close the returned rows.
+func (this *Conn) QueryRows(query string, args ...interface{}) (*easysql.Rows, error) {
+	queryx, argsx, err := this.bind(query, args)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	queryx = this.db.Rebind(queryx)
-	return this.excter.SelectContext(this.Context(), dest, queryx, argsx...)
-}
 
-// select count(*) from ...
-func (this *Conn) QueryCount(query string, args ...interface{}) (int64, error) {
-	queryx, argsx, err := sqlx.In(query, args...)
+	// the deadline and the pinned connection cover reading the rows, they end when the rows are closed
+	ctx, cancel := easysql.TimeoutContext(this.Context(), this.timeout)
+	var rows *sqlx.Rows
+	unpin := func() {}
+	err = this.runContext(ctx, easysql.OpQuery, queryx, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.route(ctx, func(q execAndQuery) (int64, error) {
+			p, release, err := this.pin(ctx, q)
+			if err != nil {
+				return -1, err
+			}
+			if rows, err = p.QueryxContext(ctx, query, args...); err != nil {
+				release()
+				return -1, err
+			}
+			unpin = release
+			return -1, nil
+		})
+	})
 	if err != nil {
-		return 0, err
+		cancel()
+		return nil, err
 	}
-	queryx = this.db.Rebind(queryx)
+	return easysql.NewRowsWithCancel(rows, func() {
+		unpin()
+		cancel()
+	}), nil
+}
 
-	rows, err := this.excter.QueryxContext(this.Context(), queryx, argsx...)
+// call fn for each row of the result, one row at a time.
+// conn.Iterate("select * from logs where day=?", []interface{}{day}, func(row QItem) error {...})
+func (this *Conn) Iterate(query string, args []interface{}, fn func(row QItem) error) error {
+	rows, err := this.QueryRows(query, args...)
 	if err != nil {
-		return 0, err
-	}
-	defer rows.Close()
-
-	var count int64 = 0
-	for rows.Next() {
-		err := rows.Scan(&count)
-		if err != nil {
-			return 0, err
-		}
+		return err
 	}
-
-	return count, nil
-}
-
-func (this *Conn) Close() error {
-	return nil
+	return rows.ForEach(fn)
 }
 
-func (item QItem) AsMap() map[string]interface{} {
-	return item
-}
-
-func (item QItem) GetColumnString(key string) string {
-	return item[key].(string)
-}
-
-func (item QItem) GetColumnInt64(key string) int64 {
-	return item[key].(int64)
-}
-
-func (item QItem) GetColumnFloat64(key string) float64 {
-	return item[key].(float64)
-}
-
-func (item QItem) ToInt64(keys ...string) QItem {
-	for _, key := range keys {
-		if ss, ok := item[key].(string); ok {
-			item[key], _ = strconv.ParseInt(ss, 10, 64)
-		}
+// query database
+func (this *Conn) Select(dest interface{}, query string, args ...interface{}) error {
+	queryx, argsx, err := this.bind(query, args)
+	if err != nil {
+		return err
 	}
-	return item
-}
 
-func (item QItem) IntToBool(keys ...string) QItem {
-	for _, key := range keys {
-		if ss, ok := item[key].(string); ok {
-			n, _ := strconv.ParseInt(ss, 10, 64)
-			if n == 0 {
-				item[key] = false
-			} else {
-				item[key] = true
+	n0 := easysql.DestLen(dest)
+	return this.run(easysql.OpSelect, queryx, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.read(ctx, func(q execAndQuery) (int64, error) {
+			easysql.TruncateDest(dest, n0) //sqlx appends, drop the rows of a failed attempt
+			if err := q.SelectContext(ctx, dest, query, args...); err != nil {
+				return -1, err
 			}
-		}
-	}
-	return item
-}
-
-func (item QItem) ToFloat64(keys ...string) QItem {
-	for _, key := range keys {
-		if ss, ok := item[key].(string); ok {
-			item[key], _ = strconv.ParseFloat(ss, 64)
-		}
-	}
-	return item
+			return easysql.DestLen(dest), nil
+		})
+	})
 }
 
-func (item QItem) Base64Encode(keys ...string) QItem {
-	for _, key := range keys {
-		if ss, ok := item[key].(string); ok {
-			item[key] = base64.StdEncoding.EncodeToString([]byte(ss))
-		}
+// query one row into a struct or a scalar.
+// returns easysql.ErrNoRows if the query matched no row.
+func (this *Conn) Get(dest interface{}, query string, args ...interface{}) error {
+	queryx, argsx, err := this.bind(query, args)
+	if err != nil {
+		return err
 	}
-	return item
-}
 
-func (array *QArray) ToInt64(key ...string) *QArray {
-	for _, item := range *array {
-		item.ToInt64(key...)
-	}
-	return array
+	return this.run(easysql.OpGet, queryx, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.read(ctx, func(q execAndQuery) (int64, error) {
+			err := q.GetContext(ctx, dest, query, args...)
+			if err == sql.ErrNoRows {
+				return 0, err
+			} else if err != nil {
+				return -1, err
+			}
+			return 1, nil
+		})
+	})
 }
 
-func (array *QArray) Shuffle() *QArray {
-	nLen := len(*array)
-
-	if nLen < 2 {
-		return array
-	}
-
-	rand.Seed(time.Now().UnixNano())
-	dest := make([]QItem, nLen)
-	perm := rand.Perm(nLen)
-	for i, v := range perm {
-		dest[v] = (*array)[i]
+// named parameters, bound from a map[string]interface{} or a struct with db tags.
+// slices are expanded for IN clauses.
+// a literal "::" (type cast) must be written as "::::".
+// conn.ExecNamed("update accounts set age=:age where userid in (:ids)", map[string]interface{}{"age": 20, "ids": []int{1, 2}})
+func (this *Conn) ExecNamed(cmd string, arg interface{}) error {
+	query, args, err := sqlx.Named(cmd, arg)
+	if err != nil {
+		return err
 	}
-
-	*array = dest
-
-	return array
+	return this.Exec(query, args...)
 }
 
-func (array *QArray) IntToBool(key ...string) *QArray {
-	for _, item := range *array {
-		item.IntToBool(key...)
+func (this *Conn) QueryNamed(query string, arg interface{}) (QArray, error) {
+	queryx, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return nil, err
 	}
-	return array
+	return this.Query(queryx, args...)
 }
 
-func (array *QArray) ToFloat64(key ...string) *QArray {
-	for _, item := range *array {
-		item.ToFloat64(key...)
+func (this *Conn) SelectNamed(dest interface{}, query string, arg interface{}) error {
+	queryx, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return err
 	}
-	return array
+	return this.Select(dest, queryx, args...)
 }
 
-func (array *QArray) Base64Encode(key ...string) *QArray {
-	for _, item := range *array {
-		item.Base64Encode(key...)
+// select count(*) from ...
+func (this *Conn) QueryCount(query string, args ...interface{}) (int64, error) {
+	queryx, argsx, err := this.bind(query, args)
+	if err != nil {
+		return 0, err
 	}
-	return array
-}
 
-func (array *QArray) ToRawArray() []interface{} {
-	arr := make([]interface{}, 0, len(*array))
+	var count int64 = 0
+	err = this.run(easysql.OpCount, queryx, argsx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return this.read(ctx, func(q execAndQuery) (int64, error) {
+			rows, err := q.QueryxContext(ctx, query, args...)
+			if err != nil {
+				return -1, err
+			}
+			defer rows.Close()
 
-	for _, item := range *array {
-		arr = append(arr, map[string]interface{}(item))
+			for rows.Next() {
+				if err := rows.Scan(&count); err != nil {
+					return -1, err
+				}
+			}
+			return 1, rows.Err()
+		})
+	})
+	if err != nil {
+		return 0, err
 	}
+	return count, nil
+}
 
-	return arr
+func (this *Conn) Close() error {
+	return nil
 }
@@ -0,0 +1,82 @@
+package cockroach
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/carr123/easysql"
+)
+
+func init() {
+	easysql.Register("cockroach", openURL)
+	easysql.Register("cockroachdb", openURL)
+}
+
+// several nodes can be listed, comma separated. the DBServer is then created by NewCluster:
+// "cockroach://root@10.0.0.1:26257,10.0.0.2:26257,10.0.0.3:26257/bank?sslmode=disable"
+func openURL(dataSourceName string, opts ...easysql.Option) (easysql.DB, error) {
+	opts = append(opts, func(o *easysql.Options) {
+		o.ConvertDSN = func(dsn string) (string, error) {
+			return convertURL(dsn), nil
+		}
+	})
+
+	nodes := splitNodes(convertURL(dataSourceName))
+	if len(nodes) > 1 {
+		db, err := NewCluster(nodes, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+
+	db, err := NewWithOptions(nodes[0], opts...)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// "postgresql://root@n1:26257,n2:26257/bank" => "postgresql://root@n1:26257/bank", "postgresql://root@n2:26257/bank"
+func splitNodes(dataSourceName string) []string {
+	pos := strings.Index(dataSourceName, "://")
+	if pos < 0 {
+		return []string{dataSourceName}
+	}
+
+	prefix, rest := dataSourceName[:pos+3], dataSourceName[pos+3:]
+	suffix := ""
+	if end := strings.IndexAny(rest, "/?"); end >= 0 {
+		rest, suffix = rest[:end], rest[end:]
+	}
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		prefix, rest = prefix+rest[:at+1], rest[at+1:]
+	}
+
+	hosts := strings.Split(rest, ",")
+	nodes := make([]string, len(hosts))
+	for i, host := range hosts {
+		nodes[i] = prefix + host + suffix
+	}
+	return nodes
+}
+
+// hide the password of a DSN before logging it
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	return rePassword.ReplaceAllString(dsn, "password=xxxxx")
+}
+
+var rePassword = regexp.MustCompile(`password=\S*`)
+
+// "cockroach://root@127.0.0.1:26257/bank?sslmode=disable" => "postgresql://root@127.0.0.1:26257/bank?sslmode=disable"
+func convertURL(dataSourceName string) string {
+	pos := strings.Index(dataSourceName, "://")
+	if pos < 0 {
+		return dataSourceName
+	}
+	return "postgresql" + dataSourceName[pos:]
+}
@@ -0,0 +1,71 @@
+package cockroach
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitNodes(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want []string
+	}{
+		{
+			"postgresql://root@127.0.0.1:26257/bank?sslmode=disable",
+			[]string{"postgresql://root@127.0.0.1:26257/bank?sslmode=disable"},
+		},
+		{
+			"postgresql://root@n1:26257,n2:26257,n3:26257/bank?sslmode=disable",
+			[]string{
+				"postgresql://root@n1:26257/bank?sslmode=disable",
+				"postgresql://root@n2:26257/bank?sslmode=disable",
+				"postgresql://root@n3:26257/bank?sslmode=disable",
+			},
+		},
+		{
+			"postgresql://root:secret@n1:26257,n2:26257/bank",
+			[]string{"postgresql://root:secret@n1:26257/bank", "postgresql://root:secret@n2:26257/bank"},
+		},
+		{
+			"postgresql://root@[::1]:26257,[fe80::1]:26258/bank",
+			[]string{"postgresql://root@[::1]:26257/bank", "postgresql://root@[fe80::1]:26258/bank"},
+		},
+		{
+			"postgresql://n1:26257,n2:26257?sslmode=disable",
+			[]string{"postgresql://n1:26257?sslmode=disable", "postgresql://n2:26257?sslmode=disable"},
+		},
+		{
+			// an @ in the query string is not the user info
+			"postgresql://root@n1,n2/bank?application_name=a@b",
+			[]string{"postgresql://root@n1/bank?application_name=a@b", "postgresql://root@n2/bank?application_name=a@b"},
+		},
+		{
+			"host=127.0.0.1 port=26257 dbname=bank",
+			[]string{"host=127.0.0.1 port=26257 dbname=bank"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := splitNodes(tt.dsn); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitNodes(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"postgresql://root:secret@n1:26257/bank?sslmode=disable", "postgresql://root:xxxxx@n1:26257/bank?sslmode=disable"},
+		{"postgresql://root@n1:26257/bank", "postgresql://root@n1:26257/bank"},
+		{"postgresql://root:secret@[::1]:26257/bank", "postgresql://root:xxxxx@[::1]:26257/bank"},
+		{"host=n1 user=root password=secret dbname=bank", "host=n1 user=root password=xxxxx dbname=bank"},
+	}
+
+	for _, tt := range tests {
+		if got := redactDSN(tt.dsn); got != tt.want {
+			t.Errorf("redactDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
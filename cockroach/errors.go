@@ -0,0 +1,37 @@
+package cockroach
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"github.com/carr123/easysql"
+	"github.com/lib/pq"
+)
+
+// wrap driver errors into *easysql.Error, other errors are returned as they are
+func wrapError(err error, query string) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := easysql.AsError(err); ok {
+		return err
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return &easysql.Error{
+			Code:       string(pqErr.Code),
+			Message:    pqErr.Message,
+			Constraint: pqErr.Constraint,
+			Table:      pqErr.Table,
+			Query:      query,
+			Err:        err,
+		}
+	}
+
+	if errors.Is(err, driver.ErrBadConn) {
+		return &easysql.Error{Code: easysql.CodeConnectionFailure, Message: err.Error(), Query: query, Err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,21 @@
+package cockroach
+
+import (
+	"context"
+
+	"github.com/carr123/easysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// ping the server and report its version, replication lag and pool usage.
+// cockroach replicates within the cluster and reports no replication lag.
+func (this *DBServer) Health(ctx context.Context) easysql.Health {
+	probe := easysql.HealthProbe{Backend: backendName, Version: version}
+	return easysql.CheckHealth(ctx, this.db, this.replicas, probe, this.opts.HealthThresholds)
+}
+
+func version(ctx context.Context, db *sqlx.DB) (string, error) {
+	var v string
+	err := db.GetContext(ctx, &v, "SELECT version()")
+	return v, err
+}
@@ -0,0 +1,14 @@
+package cockroach
+
+import (
+	"github.com/carr123/easysql"
+)
+
+// DefaultRetryPolicy is the RetryPolicy of cockroach DBServers unless easysql.WithRetryPolicy is given.
+// contended transactions fail with 40001 far more often on cockroach than on the other backends,
+// so it keeps the 50 attempts of crdb.ExecuteInTx, which ExecInTx used before.
+func DefaultRetryPolicy() *easysql.RetryPolicy {
+	p := easysql.DefaultRetryPolicy()
+	p.MaxAttempts = 50
+	return p
+}
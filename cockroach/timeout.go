@@ -0,0 +1,165 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// statement_timeout of a transaction is unknown after a savepoint rollback
+const unknownTimeout time.Duration = -1
+
+// a new transaction starts with the DBServer default, set by the DSN, see withStatementTimeout
+func (this *DBServer) txTimeout() *time.Duration {
+	d := this.opts.QueryTimeout
+	return &d
+}
+
+// inside a transaction, the conn timeout is applied with SET LOCAL, which lasts until the end
+// of the transaction. it is issued again whenever a statement runs with another timeout
+// than the one in effect, so a WithTimeout conn doesn't limit the later statements.
+// outside transactions see pin.
+func (this *Conn) setStatementTimeout(ctx context.Context) error {
+	if this.tx == nil || this.txTimeout == nil || *this.txTimeout == this.timeout {
+		return nil
+	}
+	_, err := this.tx.ExecContext(ctx, "SET LOCAL statement_timeout = "+strconv.FormatInt(milliseconds(this.timeout), 10))
+	if err != nil {
+		return err
+	}
+	*this.txTimeout = this.timeout
+	return nil
+}
+
+// limit runs fn on q, pinned with the conn timeout, see pin.
+func (this *Conn) limit(ctx context.Context, q execAndQuery, fn func(q execAndQuery) (int64, error)) (int64, error) {
+	p, release, err := this.pin(ctx, q)
+	if err != nil {
+		return -1, err
+	}
+	defer release()
+	return fn(p)
+}
+
+// outside transactions every connection has the DBServer default as statement_timeout,
+// set by the DSN. a conn with another timeout runs its statement on a dedicated connection
+// of db, with "SET statement_timeout" until release resets it. a connection which can't be
+// reset is discarded. inside transactions q is returned as it is, see setStatementTimeout.
+func (this *Conn) pin(ctx context.Context, q execAndQuery) (execAndQuery, func(), error) {
+	db, ok := q.(*sqlx.DB)
+	if !ok || this.timeout == this.srv.opts.QueryTimeout {
+		return q, func() {}, nil
+	}
+
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "SET statement_timeout = "+strconv.FormatInt(milliseconds(this.timeout), 10)); err != nil {
+		discard(conn)
+		return nil, nil, err
+	}
+
+	release := func() {
+		// the statement context may have ended already
+		ctx, cancel := context.WithTimeout(context.Background(), resetTimeout)
+		defer cancel()
+		if _, err := conn.ExecContext(ctx, "RESET statement_timeout"); err != nil {
+			discard(conn)
+			return
+		}
+		conn.Close()
+	}
+	return conn, release, nil
+}
+
+// how long release waits for RESET statement_timeout
+var resetTimeout = 5 * time.Second
+
+// close conn without returning it to the pool
+func discard(conn *sqlx.Conn) {
+	conn.Raw(func(driverConn interface{}) error {
+		return driver.ErrBadConn
+	})
+	conn.Close()
+}
+
+func milliseconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	ms := int64(d / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return ms
+}
+
+// set statement_timeout for every connection through the "options" connection parameter
+// "postgresql://root@127.0.0.1:26257/bank" => "postgresql://root@127.0.0.1:26257/bank?options=-c+statement_timeout%3D5000"
+func withStatementTimeout(dataSourceName string, d time.Duration) (string, error) {
+	if d <= 0 || strings.Contains(dataSourceName, "statement_timeout") {
+		return dataSourceName, nil
+	}
+	opt := "-c statement_timeout=" + strconv.FormatInt(milliseconds(d), 10)
+
+	if !strings.Contains(dataSourceName, "://") {
+		return mergeOptions(dataSourceName, opt)
+	}
+
+	u, err := url.Parse(dataSourceName)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("options", strings.TrimSpace(query.Get("options")+" "+opt))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// add opt to the options of a key=value DSN, quoted as it contains a space
+// "host=n1 options='-c search_path=app'" => "host=n1 options='-c search_path=app -c statement_timeout=5000'"
+// "host=n1 options=-csearch_path=app" => "host=n1 options='-csearch_path=app -c statement_timeout=5000'"
+func mergeOptions(dataSourceName string, opt string) (string, error) {
+	start := -1
+	for i := strings.Index(dataSourceName, "options="); i >= 0; {
+		if i == 0 || dataSourceName[i-1] == ' ' || dataSourceName[i-1] == '\t' {
+			start = i + len("options=")
+			break
+		}
+		next := strings.Index(dataSourceName[i+1:], "options=")
+		if next < 0 {
+			break
+		}
+		i += 1 + next
+	}
+	if start < 0 {
+		return strings.TrimSpace(dataSourceName + " options='" + opt + "'"), nil
+	}
+
+	rest := dataSourceName[start:]
+	if strings.HasPrefix(rest, "'") {
+		// closing quote, skipping escaped ones
+		for i := 1; i < len(rest); i++ {
+			switch rest[i] {
+			case '\\':
+				i++
+			case '\'':
+				return dataSourceName[:start+i] + " " + opt + dataSourceName[start+i:], nil
+			}
+		}
+		return "", errors.New("easysql: unterminated quoted options in DSN")
+	}
+
+	end := strings.IndexAny(rest, " \t")
+	if end < 0 {
+		end = len(rest)
+	}
+	return dataSourceName[:start] + "'" + rest[:end] + " " + opt + "'" + rest[end:], nil
+}
@@ -0,0 +1,346 @@
+package easysql
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// canonical go type of a result column
+type columnKind int
+
+const (
+	kindRaw columnKind = iota
+	kindInt
+	kindFloat
+	kindDecimal
+	kindBool
+	kindString
+	kindTime
+	kindBytes
+	kindJSON
+)
+
+var columnKinds = map[string]columnKind{
+	"TINYINT": kindInt, "SMALLINT": kindInt, "MEDIUMINT": kindInt, "INT": kindInt, "INTEGER": kindInt, "BIGINT": kindInt,
+	"INT2": kindInt, "INT4": kindInt, "INT8": kindInt, "OID": kindInt, "YEAR": kindInt,
+	"INT16": kindInt, "INT32": kindInt, "INT64": kindInt, "UINT8": kindInt, "UINT16": kindInt, "UINT32": kindInt, "UINT64": kindInt,
+
+	"FLOAT": kindFloat, "DOUBLE": kindFloat, "REAL": kindFloat, "FLOAT4": kindFloat, "FLOAT8": kindFloat,
+	"FLOAT32": kindFloat, "FLOAT64": kindFloat,
+
+	"DECIMAL": kindDecimal, "NUMERIC": kindDecimal,
+
+	"BOOL": kindBool, "BOOLEAN": kindBool,
+
+	"CHAR": kindString, "VARCHAR": kindString, "BPCHAR": kindString, "TEXT": kindString, "TINYTEXT": kindString,
+	"MEDIUMTEXT": kindString, "LONGTEXT": kindString, "STRING": kindString, "FIXEDSTRING": kindString,
+	"NAME": kindString, "UUID": kindString, "ENUM": kindString, "ENUM8": kindString, "ENUM16": kindString, "SET": kindString,
+	"INET": kindString, "INTERVAL": kindString,
+
+	"DATE": kindTime, "DATETIME": kindTime, "DATETIME64": kindTime, "TIMESTAMP": kindTime, "TIMESTAMPTZ": kindTime,
+
+	"BLOB": kindBytes, "TINYBLOB": kindBytes, "MEDIUMBLOB": kindBytes, "LONGBLOB": kindBytes,
+	"BYTEA": kindBytes, "BYTES": kindBytes, "BINARY": kindBytes, "VARBINARY": kindBytes,
+
+	"JSON": kindJSON, "JSONB": kindJSON,
+}
+
+// database type names differ per driver: "UNSIGNED BIGINT" (mysql), "INT8" (postgres),
+// "Nullable(Decimal(9, 2))" (clickhouse).
+func columnKindOf(ct *sql.ColumnType) columnKind {
+	return columnKindOfName(ct.DatabaseTypeName())
+}
+
+func columnKindOfName(typeName string) columnKind {
+	name := strings.ToUpper(strings.TrimSpace(typeName))
+	for _, wrapper := range []string{"NULLABLE(", "LOWCARDINALITY("} {
+		if strings.HasPrefix(name, wrapper) && strings.HasSuffix(name, ")") {
+			name = name[len(wrapper) : len(name)-1]
+		}
+	}
+	if pos := strings.IndexByte(name, '('); pos > 0 {
+		name = name[:pos]
+	}
+	name = strings.TrimPrefix(name, "UNSIGNED ")
+
+	return columnKinds[name]
+}
+
+// convertColumn converts a value read from the driver to the canonical type of its column.
+// values which can not be converted are returned as they are, []byte as string.
+func convertColumn(kind columnKind, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	var out interface{}
+	var err error
+	switch kind {
+	case kindInt:
+		out, err = toInt64(v)
+		if err != nil {
+			//UNSIGNED BIGINT above math.MaxInt64, uint64 from the binary protocol, text from the others
+			out, err = toUint64(v)
+		}
+	case kindFloat:
+		out, err = toFloat64(v)
+	case kindDecimal:
+		//kept exact as a string, money would be corrupted by float64. GetFloat64 converts it.
+		out, err = toDecimal(v)
+	case kindBool:
+		out, err = toBool(v)
+	case kindTime:
+		out, err = toTime(v)
+	case kindBytes:
+		out, err = toBytes(v)
+	case kindJSON:
+		out, err = toJSON(v)
+	case kindString:
+		if b, ok := v.([]byte); ok {
+			return string(b)
+		}
+		return v
+	default:
+		err = fmt.Errorf("raw value")
+	}
+
+	if err != nil {
+		if b, ok := v.([]byte); ok {
+			return string(b)
+		}
+		return v
+	}
+	return out
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch x := v.(type) {
+	case int64:
+		return x, nil
+	case int:
+		return int64(x), nil
+	case int8:
+		return int64(x), nil
+	case int16:
+		return int64(x), nil
+	case int32:
+		return int64(x), nil
+	case uint:
+		return toInt64(uint64(x))
+	case uint8:
+		return int64(x), nil
+	case uint16:
+		return int64(x), nil
+	case uint32:
+		return int64(x), nil
+	case uint64:
+		if x > math.MaxInt64 {
+			return 0, fmt.Errorf("easysql: %d overflows int64", x)
+		}
+		return int64(x), nil
+	case float32:
+		return floatToInt64(float64(x))
+	case float64:
+		return floatToInt64(x)
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case []byte:
+		return parseInt64(string(x))
+	case string:
+		return parseInt64(x)
+	case json.Number:
+		return parseInt64(string(x))
+	}
+	return 0, fmt.Errorf("easysql: can not convert %T to int64", v)
+}
+
+func toUint64(v interface{}) (uint64, error) {
+	switch x := v.(type) {
+	case uint64:
+		return x, nil
+	case []byte:
+		return strconv.ParseUint(strings.TrimSpace(string(x)), 10, 64)
+	case string:
+		return strconv.ParseUint(strings.TrimSpace(x), 10, 64)
+	}
+	return 0, fmt.Errorf("easysql: can not convert %T to uint64", v)
+}
+
+func floatToInt64(f float64) (int64, error) {
+	if f != math.Trunc(f) || f > math.MaxInt64 || f < math.MinInt64 {
+		return 0, fmt.Errorf("easysql: can not convert %v to int64", f)
+	}
+	return int64(f), nil
+}
+
+func parseInt64(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	//"12.00" from a DECIMAL column, without going through float64 which rounds large values
+	if pos := strings.IndexByte(s, '.'); pos > 0 && strings.Trim(s[pos+1:], "0") == "" {
+		if n, err := strconv.ParseInt(s[:pos], 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	if strings.Contains(s, ".") && !strings.ContainsAny(s, "eE") {
+		return 0, fmt.Errorf("easysql: can not convert %q to int64", s)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("easysql: can not convert %q to int64", s)
+	}
+	return floatToInt64(f)
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case float32:
+		//keep the short decimal form, float64(float32(0.1)) is 0.10000000149011612
+		return strconv.ParseFloat(strconv.FormatFloat(float64(x), 'g', -1, 32), 64)
+	case []byte:
+		return parseFloat64(string(x))
+	case string:
+		return parseFloat64(x)
+	case json.Number:
+		return parseFloat64(string(x))
+	case uint64:
+		return float64(x), nil
+	}
+
+	n, err := toInt64(v)
+	if err != nil {
+		return 0, fmt.Errorf("easysql: can not convert %T to float64", v)
+	}
+	return float64(n), nil
+}
+
+func parseFloat64(s string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("easysql: can not convert %q to float64", s)
+	}
+	return f, nil
+}
+
+// "12345678901234567.89" as it is. drivers returning a float (clickhouse) are formatted
+// with the shortest exact representation of the float.
+func toDecimal(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case []byte:
+		return strings.TrimSpace(string(x)), nil
+	case string:
+		return strings.TrimSpace(x), nil
+	case json.Number:
+		return string(x), nil
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	case int64, int, int8, int16, int32, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(x), nil
+	}
+	return "", fmt.Errorf("easysql: can not convert %T to decimal", v)
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch x := v.(type) {
+	case bool:
+		return x, nil
+	case []byte:
+		return parseBool(string(x))
+	case string:
+		return parseBool(x)
+	}
+
+	n, err := toInt64(v)
+	if err != nil {
+		return false, fmt.Errorf("easysql: can not convert %T to bool", v)
+	}
+	return n != 0, nil
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true, nil
+	case "0", "f", "false", "n", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("easysql: can not convert %q to bool", s)
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, nil
+	case []byte:
+		return parseTimeString(string(x), time.UTC)
+	case string:
+		return parseTimeString(x, time.UTC)
+	}
+	return time.Time{}, fmt.Errorf("easysql: can not convert %T to time.Time", v)
+}
+
+func toBytes(v interface{}) ([]byte, error) {
+	switch x := v.(type) {
+	case []byte:
+		return x, nil
+	case string:
+		return []byte(x), nil
+	}
+	return nil, fmt.Errorf("easysql: can not convert %T to []byte", v)
+}
+
+func toString(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case []byte:
+		return string(x), nil
+	case time.Time:
+		return x.Format(time.RFC3339Nano), nil
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), nil
+	case int64, int, int8, int16, int32, uint, uint8, uint16, uint32, uint64, bool, json.Number:
+		return fmt.Sprint(x), nil
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	return "", fmt.Errorf("easysql: can not convert %T to string", v)
+}
+
+// json columns are decoded to map[string]interface{}, []interface{} etc.
+// numbers are kept as json.Number like easysql.JSONB does.
+func toJSON(v interface{}) (interface{}, error) {
+	b, err := toBytes(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,121 @@
+package easysql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestColumnKindOfName(t *testing.T) {
+	tests := []struct {
+		name string
+		want columnKind
+	}{
+		{"BIGINT", kindInt},
+		{"UNSIGNED BIGINT", kindInt},
+		{"int8", kindInt},
+		{"Nullable(UInt64)", kindInt},
+		{"DOUBLE", kindFloat},
+		{"FLOAT8", kindFloat},
+		{"DECIMAL", kindDecimal},
+		{"NUMERIC", kindDecimal},
+		{"Nullable(Decimal(9, 2))", kindDecimal},
+		{"BOOL", kindBool},
+		{"VARCHAR", kindString},
+		{"LowCardinality(String)", kindString},
+		{"FixedString(16)", kindString},
+		{"TIMESTAMPTZ", kindTime},
+		{"DateTime64(3)", kindTime},
+		{"BYTEA", kindBytes},
+		{"JSONB", kindJSON},
+		{"GEOMETRY", kindRaw},
+		{"", kindRaw},
+	}
+
+	for _, tt := range tests {
+		if got := columnKindOfName(tt.name); got != tt.want {
+			t.Errorf("columnKindOfName(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConvertColumn(t *testing.T) {
+	tm := time.Date(2022, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		kind columnKind
+		in   interface{}
+		want interface{}
+	}{
+		{kindInt, nil, nil},
+		{kindInt, int64(42), int64(42)},
+		{kindInt, int32(-7), int64(-7)},
+		{kindInt, []byte("42"), int64(42)},
+		{kindInt, "12.00", int64(12)},
+		{kindInt, "12345678901234567.00", int64(12345678901234567)},
+		{kindInt, "1.5", "1.5"},
+		{kindInt, "1e3", int64(1000)},
+		{kindInt, uint64(7), int64(7)},
+		//UNSIGNED BIGINT above math.MaxInt64 is uint64 over both protocols
+		{kindInt, uint64(18446744073709551615), uint64(18446744073709551615)},
+		{kindInt, []byte("18446744073709551615"), uint64(18446744073709551615)},
+		{kindInt, "18446744073709551615", uint64(18446744073709551615)},
+		{kindInt, []byte("abc"), "abc"},
+
+		{kindFloat, float64(1.5), float64(1.5)},
+		{kindFloat, float32(0.1), float64(0.1)},
+		{kindFloat, []byte("2.25"), float64(2.25)},
+		{kindFloat, int64(3), float64(3)},
+
+		//DECIMAL stays exact
+		{kindDecimal, []byte("12345678901234567.89"), "12345678901234567.89"},
+		{kindDecimal, "0.10", "0.10"},
+		{kindDecimal, float64(19.99), "19.99"},
+		{kindDecimal, int64(100), "100"},
+
+		{kindBool, true, true},
+		{kindBool, int64(0), false},
+		{kindBool, []byte("t"), true},
+		{kindBool, []byte("maybe"), "maybe"},
+
+		{kindString, []byte("hello"), "hello"},
+		{kindString, "hello", "hello"},
+
+		{kindTime, tm, tm},
+		{kindTime, []byte("2022-05-01 10:30:00"), tm},
+		{kindTime, "2022-05-01T10:30:00Z", tm},
+
+		{kindBytes, []byte{1, 2}, []byte{1, 2}},
+		{kindBytes, "ab", []byte("ab")},
+
+		{kindJSON, []byte(`{"a":1,"b":[true]}`), map[string]interface{}{"a": json.Number("1"), "b": []interface{}{true}}},
+		{kindJSON, []byte(`[1,2]`), []interface{}{json.Number("1"), json.Number("2")}},
+		{kindJSON, []byte(`{bad`), "{bad"},
+
+		{kindRaw, []byte("raw"), "raw"},
+		{kindRaw, int64(1), int64(1)},
+	}
+
+	for _, tt := range tests {
+		got := convertColumn(tt.kind, tt.in)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("convertColumn(%d, %#v) = %#v (%T), want %#v (%T)", tt.kind, tt.in, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+func TestDecimalGetters(t *testing.T) {
+	item := QItem{"balance": convertColumn(kindDecimal, []byte("12345678901234567.89"))}
+
+	if s := item.String("balance"); s != "12345678901234567.89" {
+		t.Errorf("String = %q", s)
+	}
+	f, err := item.GetFloat64("balance")
+	if err != nil || f != 12345678901234567.89 {
+		t.Errorf("GetFloat64 = %v, %v", f, err)
+	}
+	if _, err := item.GetInt64("balance"); err == nil {
+		t.Error("GetInt64 of a fractional decimal should fail")
+	}
+}
@@ -0,0 +1,87 @@
+package easysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DB is implemented by the DBServer of every backend package
+// (mysql, postgre, cockroach, clickhouse), so application code can
+// depend on it and pick the backend at runtime.
+type DB interface {
+	NewConn() Conn
+	ExecInTx(fn func(Conn) error) error
+	ExecInTxContext(ctx context.Context, fn func(Conn) error) error
+	ExecInTxWithOptions(ctx context.Context, opts *TxOptions, fn func(Conn) error) error
+
+	// manual transaction, see Tx
+	Begin(ctx context.Context, opts *TxOptions) (*Tx, error)
+
+	// add hooks called around every statement and transaction, see Hook
+	Use(hooks ...Hook)
+
+	// statistics of the connection pool
+	Stats() sql.DBStats
+
+	// check the database, see LivenessHandler and ReadinessHandler
+	Health(ctx context.Context) Health
+
+	Close()
+}
+
+// Conn is implemented by the Conn of every backend package.
+type Conn interface {
+	Context() context.Context
+	WithContext(ctx context.Context) Conn
+
+	// reads of the returned conn skip the replicas
+	ForcePrimary() Conn
+
+	// statements of the returned conn time out after d, 0 means no timeout.
+	// the default is set by WithQueryTimeout.
+	WithTimeout(d time.Duration) Conn
+	Ping() error
+
+	// nested transaction: starts a transaction, or a SAVEPOINT when already in one.
+	ExecInTx(fn func(Conn) error) error
+
+	// callbacks run exactly once after the final outcome of the transaction.
+	AfterCommit(fn func())
+	AfterRollback(fn func(err error))
+
+	// insert update delete
+	// create table, alter index etc.
+	Exec(cmd string, args ...interface{}) error
+	ExecResult(cmd string, args ...interface{}) (Result, error)
+
+	// insert one record and return its generated id.
+	// uses LastInsertId on mysql and "RETURNING idColumn" on postgre/cockroach.
+	InsertReturningID(cmd string, idColumn string, args ...interface{}) (int64, error)
+
+	// insert many records at one shot.
+	// conn.BulkInsert("insert into files(bucket,filename)", nCol, values...)
+	BulkInsert(cmd string, nCol int, args ...interface{}) error
+	BulkInsertEx(cmd string, nCol int, args []interface{}, szSQLsurfix ...string) error
+
+	Query(query string, args ...interface{}) (QArray, error)
+	Select(dest interface{}, query string, args ...interface{}) error
+
+	// streaming queries for large results
+	QueryRows(query string, args ...interface{}) (*Rows, error)
+	Iterate(query string, args []interface{}, fn func(row QItem) error) error
+
+	// query one row into a struct or a scalar, returns ErrNoRows if nothing matched.
+	Get(dest interface{}, query string, args ...interface{}) error
+
+	// named parameters, bound from a map[string]interface{} or a struct with db tags.
+	// conn.ExecNamed("update accounts set age=:age where userid in (:ids)", map[string]interface{}{"age": 20, "ids": []int{1, 2}})
+	ExecNamed(cmd string, arg interface{}) error
+	QueryNamed(query string, arg interface{}) (QArray, error)
+	SelectNamed(dest interface{}, query string, arg interface{}) error
+
+	// select count(*) from ...
+	QueryCount(query string, args ...interface{}) (int64, error)
+
+	Close() error
+}
@@ -0,0 +1,336 @@
+package easysql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DecodeError is returned by QItem.Decode and QArray.Decode when some columns
+// could not be stored. all other columns are decoded anyway.
+type DecodeError struct {
+	Unknown []string         //columns without a matching struct field
+	Invalid map[string]error //columns whose value could not be converted to the field type
+}
+
+func (e *DecodeError) Error() string {
+	parts := make([]string, 0, 2)
+	if len(e.Unknown) > 0 {
+		parts = append(parts, "unknown columns: "+strings.Join(e.Unknown, ","))
+	}
+	if len(e.Invalid) > 0 {
+		keys := make([]string, 0, len(e.Invalid))
+		for k := range e.Invalid {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		msgs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			msgs = append(msgs, k+": "+e.Invalid[k].Error())
+		}
+		parts = append(parts, "invalid columns: "+strings.Join(msgs, "; "))
+	}
+	return "easysql: decode: " + strings.Join(parts, ", ")
+}
+
+func (e *DecodeError) empty() bool {
+	return len(e.Unknown) == 0 && len(e.Invalid) == 0
+}
+
+// Decode stores the columns of item into the struct pointed to by dest.
+// columns are matched to fields by db tag, then json tag, then lower case field name.
+// fields may be plain go types or the easysql nullable types (STRING, INT64, DATE, DATETIME, JSONB ...).
+//
+//	var user struct {
+//		UserID   int64    `db:"userid"`
+//		UserName STRING   `json:"username"`
+//		Birthday DATE     `db:"birthday"`
+//	}
+//	err := item.Decode(&user)
+func (item QItem) Decode(dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("easysql: Decode expects a pointer to a struct, got %T", dest)
+	}
+
+	derr := &DecodeError{}
+	item.decodeStruct(v.Elem(), derr, "")
+	if derr.empty() {
+		return nil
+	}
+	return derr
+}
+
+// Decode stores every item into the slice pointed to by dest, which may be *[]T or *[]*T.
+//
+//	var users []User
+//	err := arr.Decode(&users)
+func (array *QArray) Decode(dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("easysql: Decode expects a pointer to a slice, got %T", dest)
+	}
+
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("easysql: Decode expects a slice of structs, got %T", dest)
+	}
+
+	derr := &DecodeError{}
+	out := reflect.MakeSlice(slice.Type(), 0, len(*array))
+	for i, item := range *array {
+		elem := reflect.New(elemType)
+		item.decodeStruct(elem.Elem(), derr, fmt.Sprintf("row %d: ", i))
+		if isPtr {
+			out = reflect.Append(out, elem)
+		} else {
+			out = reflect.Append(out, elem.Elem())
+		}
+	}
+	slice.Set(out)
+
+	if derr.empty() {
+		return nil
+	}
+	return derr
+}
+
+func (item QItem) decodeStruct(v reflect.Value, derr *DecodeError, prefix string) {
+	fields := fieldsOf(v.Type())
+
+	for key, val := range item {
+		index, ok := fields[strings.ToLower(key)]
+		if !ok {
+			if !containsString(derr.Unknown, key) {
+				derr.Unknown = append(derr.Unknown, key)
+			}
+			continue
+		}
+
+		if err := setField(v.FieldByIndex(index), val); err != nil {
+			if derr.Invalid == nil {
+				derr.Invalid = make(map[string]error)
+			}
+			if _, dup := derr.Invalid[key]; !dup {
+				derr.Invalid[key] = fmt.Errorf("%s%w", prefix, err)
+			}
+		}
+	}
+	sort.Strings(derr.Unknown)
+}
+
+var (
+	fieldsCacheMu sync.RWMutex
+	fieldsCache   = make(map[reflect.Type]map[string][]int)
+)
+
+// column name (lower case) => field index
+func fieldsOf(t reflect.Type) map[string][]int {
+	fieldsCacheMu.RLock()
+	fields, ok := fieldsCache[t]
+	fieldsCacheMu.RUnlock()
+	if ok {
+		return fields
+	}
+
+	fields = make(map[string][]int)
+	collectFields(t, nil, fields)
+
+	fieldsCacheMu.Lock()
+	fieldsCache[t] = fields
+	fieldsCacheMu.Unlock()
+	return fields
+}
+
+func collectFields(t reflect.Type, parent []int, fields map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		index := append(append([]int{}, parent...), i)
+		name := tagName(f.Tag.Get("db"))
+		if name == "" {
+			name = tagName(f.Tag.Get("json"))
+		}
+		if name == "-" {
+			continue
+		}
+
+		//embedded structs without a tag contribute their own fields
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct && !reflect.PtrTo(f.Type).Implements(scannerType) {
+			collectFields(f.Type, index, fields)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		name = strings.ToLower(name)
+		if _, dup := fields[name]; !dup || len(index) < len(fields[name]) {
+			fields[name] = index
+		}
+	}
+}
+
+func tagName(tag string) string {
+	if pos := strings.IndexByte(tag, ','); pos >= 0 {
+		tag = tag[:pos]
+	}
+	return strings.TrimSpace(tag)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func setField(fv reflect.Value, val interface{}) error {
+	//easysql nullable types, pq arrays etc.
+	if fv.CanAddr() {
+		if scanner, ok := fv.Addr().Interface().(sql.Scanner); ok {
+			return scanner.Scan(scannerValue(val))
+		}
+	}
+
+	if val == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+
+	if fv.Kind() == reflect.Ptr {
+		elem := reflect.New(fv.Type().Elem())
+		if err := setField(elem.Elem(), val); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		s, err := toString(val)
+		if err != nil {
+			return err
+		}
+		fv.SetString(s)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt64(val)
+		if err != nil {
+			return err
+		}
+		if fv.OverflowInt(n) {
+			return fmt.Errorf("easysql: %d overflows %s", n, fv.Type())
+		}
+		fv.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u, ok := val.(uint64); ok {
+			fv.SetUint(u)
+			return nil
+		}
+		n, err := toInt64(val)
+		if err != nil {
+			return err
+		}
+		if n < 0 || fv.OverflowUint(uint64(n)) {
+			return fmt.Errorf("easysql: %d overflows %s", n, fv.Type())
+		}
+		fv.SetUint(uint64(n))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat64(val)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+		return nil
+
+	case reflect.Bool:
+		b, err := toBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+		return nil
+
+	case reflect.Interface:
+		rv := reflect.ValueOf(val)
+		if !rv.Type().AssignableTo(fv.Type()) {
+			return fmt.Errorf("easysql: can not assign %T to %s", val, fv.Type())
+		}
+		fv.Set(rv)
+		return nil
+	}
+
+	if fv.Type() == timeType {
+		tm, err := toTime(val)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(tm))
+		return nil
+	}
+
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8 {
+		b, err := toBytes(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBytes(append([]byte(nil), b...))
+		return nil
+	}
+
+	//maps, slices and structs are filled from json
+	var bin []byte
+	switch x := val.(type) {
+	case []byte:
+		bin = x
+	case string:
+		bin = []byte(x)
+	default:
+		var err error
+		if bin, err = json.Marshal(x); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(bin, fv.Addr().Interface())
+}
+
+// decoded json goes back to text, so that JSONB etc. can scan it
+func scannerValue(val interface{}) interface{} {
+	switch x := val.(type) {
+	case map[string]interface{}, []interface{}:
+		if bin, err := json.Marshal(x); err == nil {
+			return bin
+		}
+	case json.Number:
+		return string(x)
+	}
+	return val
+}
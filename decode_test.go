@@ -0,0 +1,232 @@
+package easysql
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type decodeBase struct {
+	ID      int64 `db:"id"`
+	Created time.Time
+}
+
+type decodeUser struct {
+	decodeBase
+	UserName string  `db:"username"`
+	Email    string  `json:"email,omitempty"`
+	Age      int     //matched by lower case field name
+	Score    float64 `db:"score"`
+	Active   bool    `db:"active"`
+	Nick     *string `db:"nick"`
+	Level    *int    `db:"level"`
+	Balance  string  `db:"balance"`
+	Ignored  string  `db:"-"`
+	secret   string
+}
+
+func TestQItemDecode(t *testing.T) {
+	created := time.Date(2022, 5, 1, 10, 30, 0, 0, time.UTC)
+	item := QItem{
+		"id":       int64(7),
+		"created":  created,
+		"username": "alice",
+		"email":    []byte("alice@example.com"),
+		"AGE":      int64(30),
+		"score":    "9.5",
+		"active":   int64(1),
+		"nick":     "al",
+		"level":    nil,
+		"balance":  "12345678901234567.89",
+	}
+
+	var u decodeUser
+	if err := item.Decode(&u); err != nil {
+		t.Fatal(err)
+	}
+
+	nick := "al"
+	want := decodeUser{
+		decodeBase: decodeBase{ID: 7, Created: created},
+		UserName:   "alice",
+		Email:      "alice@example.com",
+		Age:        30,
+		Score:      9.5,
+		Active:     true,
+		Nick:       &nick,
+		Balance:    "12345678901234567.89",
+	}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("Decode = %+v, want %+v", u, want)
+	}
+}
+
+func TestQItemDecodeScanners(t *testing.T) {
+	var row struct {
+		Name     STRING  `db:"name"`
+		Missing  STRING  `db:"missing"`
+		Birthday DATE    `db:"birthday"`
+		Price    FLOAT64 `db:"price"`
+		Attrs    JSONB   `db:"attrs"`
+		Tags     []string
+	}
+	item := QItem{
+		"name":     "bob",
+		"missing":  nil,
+		"birthday": time.Date(1990, 3, 4, 0, 0, 0, 0, time.UTC),
+		"price":    "19.99",
+		"attrs":    map[string]interface{}{"color": "red", "size": json.Number("42")},
+		"tags":     []interface{}{"a", "b"},
+	}
+
+	if err := item.Decode(&row); err != nil {
+		t.Fatal(err)
+	}
+	if !row.Name.Valid || row.Name.String() != "bob" {
+		t.Errorf("Name = %+v", row.Name)
+	}
+	if row.Missing.Valid {
+		t.Errorf("Missing = %+v, want NULL", row.Missing)
+	}
+	if got := row.Birthday.ToTime().Format("2006-01-02"); got != "1990-03-04" {
+		t.Errorf("Birthday = %s", got)
+	}
+	if !row.Price.Valid || row.Price.Float64 != 19.99 {
+		t.Errorf("Price = %+v", row.Price)
+	}
+	if row.Attrs.KV["color"] != "red" || row.Attrs.KV["size"] != json.Number("42") {
+		t.Errorf("Attrs = %+v", row.Attrs.KV)
+	}
+	if !reflect.DeepEqual(row.Tags, []string{"a", "b"}) {
+		t.Errorf("Tags = %v", row.Tags)
+	}
+}
+
+func TestQItemDecodeUnsigned(t *testing.T) {
+	var row struct {
+		Big   uint64 `db:"big"`
+		Small uint8  `db:"small"`
+	}
+
+	if err := (QItem{"big": uint64(18446744073709551615), "small": int64(200)}).Decode(&row); err != nil {
+		t.Fatal(err)
+	}
+	if row.Big != 18446744073709551615 || row.Small != 200 {
+		t.Errorf("Decode = %+v", row)
+	}
+
+	err := QItem{"small": int64(300)}.Decode(&row)
+	var derr *DecodeError
+	if !errors.As(err, &derr) || derr.Invalid["small"] == nil {
+		t.Errorf("overflow: err = %v, want DecodeError on small", err)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	item := QItem{
+		"id":      int64(1),
+		"zeta":    1,
+		"alpha":   2,
+		"age":     "thirty",
+		"created": "not a time",
+		"secret":  "x", //unexported
+		"ignored": "x", //db:"-"
+	}
+
+	var u decodeUser
+	err := item.Decode(&u)
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Fatalf("err = %v, want *DecodeError", err)
+	}
+
+	if !reflect.DeepEqual(derr.Unknown, []string{"alpha", "ignored", "secret", "zeta"}) {
+		t.Errorf("Unknown = %v", derr.Unknown)
+	}
+	if len(derr.Invalid) != 2 || derr.Invalid["age"] == nil || derr.Invalid["created"] == nil {
+		t.Errorf("Invalid = %v", derr.Invalid)
+	}
+	//the other columns are decoded anyway
+	if u.ID != 1 {
+		t.Errorf("ID = %d, want 1", u.ID)
+	}
+
+	want := `easysql: decode: unknown columns: alpha,ignored,secret,zeta, invalid columns: age: ` + derr.Invalid["age"].Error() +
+		`; created: ` + derr.Invalid["created"].Error()
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestDecodeBadDest(t *testing.T) {
+	var u decodeUser
+	var users []decodeUser
+	var ints []int
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"QItem into struct value", QItem{}.Decode(u)},
+		{"QItem into nil pointer", QItem{}.Decode((*decodeUser)(nil))},
+		{"QItem into slice", QItem{}.Decode(&users)},
+		{"QArray into slice value", (&QArray{}).Decode(users)},
+		{"QArray into struct", (&QArray{}).Decode(&u)},
+		{"QArray into []int", (&QArray{}).Decode(&ints)},
+	}
+	for _, tt := range tests {
+		if tt.err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+func TestQArrayDecode(t *testing.T) {
+	arr := QArray{
+		{"id": int64(1), "username": "alice"},
+		{"id": int64(2), "username": "bob"},
+	}
+
+	var users []decodeUser
+	if err := arr.Decode(&users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].ID != 1 || users[1].UserName != "bob" {
+		t.Errorf("Decode = %+v", users)
+	}
+
+	var ptrs []*decodeUser
+	if err := arr.Decode(&ptrs); err != nil {
+		t.Fatal(err)
+	}
+	if len(ptrs) != 2 || ptrs[0].UserName != "alice" || ptrs[1].ID != 2 {
+		t.Errorf("Decode = %+v", ptrs)
+	}
+}
+
+func TestQArrayDecodeError(t *testing.T) {
+	arr := QArray{
+		{"id": int64(1), "age": int64(3)},
+		{"id": "x", "age": int64(4)},
+		{"id": "y", "other": 1},
+	}
+
+	var users []decodeUser
+	err := arr.Decode(&users)
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Fatalf("err = %v, want *DecodeError", err)
+	}
+	if len(users) != 3 || users[0].Age != 3 || users[1].Age != 4 {
+		t.Errorf("rows = %+v", users)
+	}
+	if !reflect.DeepEqual(derr.Unknown, []string{"other"}) {
+		t.Errorf("Unknown = %v", derr.Unknown)
+	}
+	//the first failing row is reported
+	if got := derr.Invalid["id"]; got == nil || got.Error()[:7] != "row 1: " {
+		t.Errorf("Invalid[id] = %v, want an error of row 1", got)
+	}
+}
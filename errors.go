@@ -0,0 +1,156 @@
+package easysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+var (
+	// ErrNoRows is returned by Conn.Get when the query matched no row.
+	// it is sql.ErrNoRows, so errors.Is works with either.
+	ErrNoRows = sql.ErrNoRows
+
+	ErrNotSupported = errors.New("easysql: not supported by this backend")
+
+	// returned without touching the database while the circuit breaker is open, see WithCircuitBreaker
+	ErrCircuitOpen = errors.New("easysql: circuit breaker is open")
+
+	// returned by the QItem getters
+	ErrColumnNotFound = errors.New("easysql: column not found")
+	ErrNullValue      = errors.New("easysql: column is NULL")
+)
+
+// SQLSTATE codes used by the classification helpers.
+// mysql and clickhouse errors are mapped to them by the backend packages.
+const (
+	CodeUniqueViolation      = "23505"
+	CodeForeignKeyViolation  = "23503"
+	CodeNotNullViolation     = "23502"
+	CodeCheckViolation       = "23514"
+	CodeSerializationFailure = "40001"
+	CodeDeadlock             = "40P01"
+	CodeLockNotAvailable     = "55P03"
+	CodeQueryCanceled        = "57014"
+	CodeConnectionFailure    = "08006"
+	CodeTooManyConnections   = "53300"
+)
+
+// Error wraps the database errors returned by every backend, so they can be
+// handled without knowing the driver. the driver error stays reachable with errors.As.
+//
+//	if easysql.IsUniqueViolation(err) {
+//		return ErrUserExists
+//	}
+type Error struct {
+	Code       string //SQLSTATE, "" if unknown
+	Number     int    //native error number of mysql and clickhouse, 0 on postgre/cockroach
+	Message    string
+	Constraint string //violated constraint (or key) when the database reports it
+	Table      string
+	Query      string //the failing statement
+	Err        error  //the driver error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// SQLState returns Code, the same method as *pq.Error.
+func (e *Error) SQLState() string {
+	return e.Code
+}
+
+// AsError returns the *Error wrapped in err, if any.
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// SQLState returns the SQLSTATE of err, "" if unknown.
+func SQLState(err error) string {
+	var e interface{ SQLState() string }
+	if errors.As(err, &e) {
+		return e.SQLState()
+	}
+	return ""
+}
+
+func IsUniqueViolation(err error) bool {
+	return SQLState(err) == CodeUniqueViolation
+}
+
+func IsForeignKeyViolation(err error) bool {
+	return SQLState(err) == CodeForeignKeyViolation
+}
+
+func IsNotNullViolation(err error) bool {
+	return SQLState(err) == CodeNotNullViolation
+}
+
+func IsCheckViolation(err error) bool {
+	return SQLState(err) == CodeCheckViolation
+}
+
+func IsDeadlock(err error) bool {
+	return SQLState(err) == CodeDeadlock
+}
+
+func IsSerializationFailure(err error) bool {
+	return SQLState(err) == CodeSerializationFailure
+}
+
+// IsLockTimeout reports lock wait timeouts (mysql 1205) and NOWAIT failures (postgre).
+func IsLockTimeout(err error) bool {
+	return SQLState(err) == CodeLockNotAvailable
+}
+
+// IsTimeout reports statements canceled by a context deadline or a server side timeout.
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || SQLState(err) == CodeQueryCanceled {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsConnectionError reports errors of the connection rather than of the statement:
+// refused or broken connections, SQLSTATE class 08, too many connections.
+// a canceled or expired context is not a connection error, see IsTimeout.
+func IsConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	code := SQLState(err)
+	if strings.HasPrefix(code, "08") || code == CodeTooManyConnections {
+		return true
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && !netErr.Timeout()
+}
@@ -0,0 +1,68 @@
+package easysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"context canceled", context.Canceled, false},
+		{"deadline exceeded", context.DeadlineExceeded, false},
+		{"wrapped deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), false},
+		{"canceled statement", &Error{Code: CodeQueryCanceled, Err: context.DeadlineExceeded}, false},
+		{"net timeout", &net.DNSError{Err: "i/o timeout", IsTimeout: true}, false},
+		{"unique violation", &Error{Code: CodeUniqueViolation}, false},
+		{"plain error", errors.New("syntax error"), false},
+
+		{"connection failure", &Error{Code: CodeConnectionFailure}, true},
+		{"connection exception class", &Error{Code: "08001"}, true},
+		{"too many connections", &Error{Code: CodeTooManyConnections}, true},
+		{"bad conn", driver.ErrBadConn, true},
+		{"eof", io.EOF, true},
+		{"unexpected eof", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"refused", syscall.ECONNREFUSED, true},
+		{"reset", syscall.ECONNRESET, true},
+		{"op error", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{"net error", &net.DNSError{Err: "no such host", IsNotFound: true}, true},
+	}
+
+	for _, tt := range tests {
+		if got := IsConnectionError(tt.err); got != tt.want {
+			t.Errorf("%s: IsConnectionError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"deadline exceeded", context.DeadlineExceeded, true},
+		{"wrapped deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), true},
+		{"query canceled", &Error{Code: CodeQueryCanceled}, true},
+		{"net timeout", &net.DNSError{Err: "i/o timeout", IsTimeout: true}, true},
+		{"context canceled", context.Canceled, false},
+		{"connection failure", &Error{Code: CodeConnectionFailure}, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTimeout(tt.err); got != tt.want {
+			t.Errorf("%s: IsTimeout(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
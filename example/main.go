@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -66,7 +69,7 @@ func demo2() {
 
 	if false {
 		//in 后面的数组不能为空数组,否则程序报错
-		//返回的是 []map[string]interface{}, 注意返回的字段类型可能和数据库字段类型不对应
+		//返回的是 []map[string]interface{}, 字段值按数据库字段类型转换为 int64, float64, bool, string, time.Time, []byte 或解析后的json
 		v, err := conn.Query("select * from accounts where userid in (?)", []int{1, 2, 3})
 		if err != nil {
 			fmt.Println("err:", err)
@@ -110,6 +113,23 @@ func demo2() {
 		fmt.Println("output:", string(bin))
 	}
 
+	if false {
+		//只查询一条记录用Get. 没有记录时返回easysql.ErrNoRows
+		var user struct {
+			UserID   int64  `db:"userid" json:"id"`
+			UserName STRING `db:"username" json:"name"`
+		}
+		err := conn.Get(&user, "select userid,username from accounts where userid=?", 1)
+		if errors.Is(err, easysql.ErrNoRows) {
+			fmt.Println("not found")
+			return
+		} else if err != nil {
+			fmt.Println("err:", err)
+			return
+		}
+		fmt.Println(user.UserID, user.UserName)
+	}
+
 	if false {
 		//查询数量用QueryCount更方便
 		nNum, err := conn.QueryCount("select count(*) from accounts")
@@ -171,7 +191,7 @@ func TransactionDemo() {
 	go func() {
 		defer wg.Done()
 
-		err := db.ExecInTx(func(conn *dbserver.Conn) error {
+		err := db.ExecInTx(func(conn easysql.Conn) error {
 			log.Println("tx start")
 			v, err := conn.Query("select userid,address,birthday,createat,age from accounts where address=? for update", "suzhou")
 			if err != nil {
@@ -286,3 +306,18 @@ func arraydemo() {
 		fmt.Println(err)
 	}
 }
+
+func HookDemo() {
+	//hooks see every statement after sqlx.In/Rebind, and may rewrite or abort it
+	db.Use(easysql.HookFuncs{
+		BeforeFunc: func(ctx context.Context, ev *easysql.QueryEvent) (context.Context, error) {
+			if ev.Op == easysql.OpExec && strings.HasPrefix(strings.ToLower(ev.Query), "drop ") {
+				return ctx, errors.New("drop is not allowed")
+			}
+			return ctx, nil
+		},
+		AfterFunc: func(ctx context.Context, ev *easysql.QueryEvent) {
+			log.Println(ev.Op, ev.Query, ev.Duration, ev.Rows, ev.Err)
+		},
+	})
+}
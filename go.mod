@@ -4,10 +4,13 @@ go 1.17
 
 require (
 	github.com/ClickHouse/clickhouse-go v1.5.4
-	github.com/cockroachdb/cockroach-go/v2 v2.2.16
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/lib/pq v1.10.6
 )
 
-require github.com/cloudflare/golz4 v0.0.0-20150217214814-ef862a3cdc58 // indirect
+require (
+	github.com/cloudflare/golz4 v0.0.0-20150217214814-ef862a3cdc58 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/stretchr/testify v1.7.0 // indirect
+)
@@ -0,0 +1,201 @@
+package easysql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type HealthStatus string
+
+const (
+	StatusUp       HealthStatus = "up"
+	StatusDegraded HealthStatus = "degraded" //reachable, but a threshold is exceeded or a replica is down
+	StatusDown     HealthStatus = "down"
+)
+
+// Health is the result of DBServer.Health.
+type Health struct {
+	Status    HealthStatus `json:"status"`
+	Backend   string       `json:"backend"`
+	Version   string       `json:"version,omitempty"`
+	Latency   Duration     `json:"latency"`
+	CheckedAt time.Time    `json:"checked_at"`
+
+	// replication lag of the replicas (the largest one), or of the server itself
+	// when it is a replica. nil when the backend doesn't report it.
+	ReplicationLag *Duration `json:"replication_lag,omitempty"`
+
+	Pool     PoolHealth     `json:"pool"`
+	Replicas *ReplicaHealth `json:"replicas,omitempty"` //nil without replicas
+
+	Reasons []string `json:"reasons,omitempty"` //why the status is not up
+}
+
+type ReplicaHealth struct {
+	Healthy int `json:"healthy"`
+	Total   int `json:"total"`
+}
+
+type PoolHealth struct {
+	Open       int     `json:"open"`
+	InUse      int     `json:"in_use"`
+	Idle       int     `json:"idle"`
+	MaxOpen    int     `json:"max_open"` //0 means unlimited
+	WaitCount  int64   `json:"wait_count"`
+	Saturation float64 `json:"saturation"` //InUse / MaxOpen, 0 when unlimited
+}
+
+// Duration is a time.Duration marshaled to json as a string like "1.5ms".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// HealthThresholds above which Health reports StatusDegraded. 0 disables a threshold.
+type HealthThresholds struct {
+	MaxLatency        time.Duration
+	MaxSaturation     float64
+	MaxReplicationLag time.Duration
+	Timeout           time.Duration //of the whole check when ctx has no deadline
+}
+
+func DefaultHealthThresholds() HealthThresholds {
+	return HealthThresholds{
+		MaxLatency:        time.Second,
+		MaxSaturation:     0.9,
+		MaxReplicationLag: 30 * time.Second,
+		Timeout:           5 * time.Second,
+	}
+}
+
+func WithHealthThresholds(t HealthThresholds) Option {
+	return func(o *Options) {
+		o.HealthThresholds = t
+	}
+}
+
+// HealthProbe holds the backend specific queries of a health check, used by the backend packages.
+type HealthProbe struct {
+	Backend string
+	Version func(ctx context.Context, db *sqlx.DB) (string, error)
+	// ok is false when db is not a replica or the lag is unknown
+	ReplicationLag func(ctx context.Context, db *sqlx.DB) (lag time.Duration, ok bool, err error)
+}
+
+// CheckHealth checks db and its replicas. used by the backend packages.
+func CheckHealth(ctx context.Context, db *sqlx.DB, replicas *ReplicaSet, probe HealthProbe, t HealthThresholds) Health {
+	if _, ok := ctx.Deadline(); !ok && t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+
+	h := Health{Status: StatusUp, Backend: probe.Backend, CheckedAt: time.Now()}
+	degrade := func(reason string) {
+		if h.Status == StatusUp {
+			h.Status = StatusDegraded
+		}
+		h.Reasons = append(h.Reasons, reason)
+	}
+
+	stats := db.Stats()
+	h.Pool = PoolHealth{
+		Open:      stats.OpenConnections,
+		InUse:     stats.InUse,
+		Idle:      stats.Idle,
+		MaxOpen:   stats.MaxOpenConnections,
+		WaitCount: stats.WaitCount,
+	}
+	if stats.MaxOpenConnections > 0 {
+		h.Pool.Saturation = float64(stats.InUse) / float64(stats.MaxOpenConnections)
+	}
+
+	start := time.Now()
+	err := db.PingContext(ctx)
+	h.Latency = Duration(time.Since(start))
+	if err != nil {
+		h.Status = StatusDown
+		h.Reasons = append(h.Reasons, "ping: "+err.Error())
+		return h
+	}
+
+	if probe.Version != nil {
+		if v, err := probe.Version(ctx, db); err == nil {
+			h.Version = v
+		}
+	}
+
+	if t.MaxLatency > 0 && time.Duration(h.Latency) > t.MaxLatency {
+		degrade("latency " + time.Duration(h.Latency).String() + " above " + t.MaxLatency.String())
+	}
+	if t.MaxSaturation > 0 && h.Pool.Saturation >= t.MaxSaturation {
+		degrade("pool saturated")
+	}
+
+	//lag of the replicas, or of db itself when there are none
+	lagDBs := replicas.DBs()
+	if len(lagDBs) == 0 {
+		lagDBs = []*sqlx.DB{db}
+	} else {
+		healthy, total := replicas.Healthy()
+		h.Replicas = &ReplicaHealth{Healthy: healthy, Total: total}
+		if healthy < total {
+			degrade("replicas down")
+		}
+	}
+
+	if probe.ReplicationLag != nil {
+		for _, lagDB := range lagDBs {
+			lag, ok, err := probe.ReplicationLag(ctx, lagDB)
+			if err != nil || !ok {
+				continue
+			}
+			if h.ReplicationLag == nil || Duration(lag) > *h.ReplicationLag {
+				d := Duration(lag)
+				h.ReplicationLag = &d
+			}
+		}
+	}
+	if h.ReplicationLag != nil && t.MaxReplicationLag > 0 && time.Duration(*h.ReplicationLag) > t.MaxReplicationLag {
+		degrade("replication lag " + time.Duration(*h.ReplicationLag).String() + " above " + t.MaxReplicationLag.String())
+	}
+
+	return h
+}
+
+// LivenessHandler serves the health of db as json for a Kubernetes liveness probe.
+// it always answers 200 with the report, a database outage shouldn't get the process restarted.
+//
+//	http.Handle("/healthz", easysql.LivenessHandler(db))
+func LivenessHandler(db DB) http.Handler {
+	return healthHandler(db, false)
+}
+
+// ReadinessHandler serves the health of db as json for a Kubernetes readiness probe.
+// it answers 503 while the database is down, so no traffic is sent.
+//
+//	http.Handle("/readyz", easysql.ReadinessHandler(db))
+func ReadinessHandler(db DB) http.Handler {
+	return healthHandler(db, true)
+}
+
+func healthHandler(db DB, readiness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := db.Health(r.Context())
+
+		code := http.StatusOK
+		if readiness && h.Status == StatusDown {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(h)
+	})
+}
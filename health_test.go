@@ -0,0 +1,60 @@
+package easysql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type healthDB struct {
+	DB
+	status HealthStatus
+}
+
+func (this *healthDB) Health(ctx context.Context) Health {
+	return Health{Status: this.status, Backend: "fake"}
+}
+
+func TestHealthHandlers(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(DB) http.Handler
+		status  HealthStatus
+		code    int
+	}{
+		{"liveness up", LivenessHandler, StatusUp, http.StatusOK},
+		{"liveness degraded", LivenessHandler, StatusDegraded, http.StatusOK},
+		{"liveness down", LivenessHandler, StatusDown, http.StatusOK},
+		{"readiness up", ReadinessHandler, StatusUp, http.StatusOK},
+		{"readiness degraded", ReadinessHandler, StatusDegraded, http.StatusOK},
+		{"readiness down", ReadinessHandler, StatusDown, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		// the path doesn't matter, only the handler
+		for _, path := range []string{"/healthz", "/readyz", "/"} {
+			w := httptest.NewRecorder()
+			tt.handler(&healthDB{status: tt.status}).ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+			if w.Code != tt.code {
+				t.Errorf("%s %s: code %d, want %d", tt.name, path, w.Code, tt.code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("%s: Content-Type %q", tt.name, ct)
+			}
+
+			var h struct {
+				Status  HealthStatus `json:"status"`
+				Backend string       `json:"backend"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &h); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if h.Status != tt.status || h.Backend != "fake" {
+				t.Errorf("%s: body %+v", tt.name, h)
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package easysql
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// operations reported in QueryEvent.Op
+const (
+	OpExec       = "exec"
+	OpQuery      = "query"
+	OpSelect     = "select"
+	OpGet        = "get"
+	OpCount      = "count"
+	OpBulkInsert = "bulkinsert"
+	OpTx         = "tx" // a whole ExecInTx attempt, statements inside it are reported as well
+	OpBegin      = "begin"
+	OpCommit     = "commit"
+	OpRollback   = "rollback"
+	OpSavepoint  = "savepoint" // SAVEPOINT, ROLLBACK TO SAVEPOINT and RELEASE SAVEPOINT of a nested ExecInTx
+)
+
+// QueryEvent describes one database operation passed to the hooks.
+type QueryEvent struct {
+	Op      string
+	Backend string // "mysql", "postgresql", "cockroachdb" or "clickhouse"
+	InTx    bool
+
+	// final SQL after sqlx.In and Rebind. Before hooks may change Query and Args.
+	Query string
+	Args  []interface{}
+
+	// set before After is called
+	Start    time.Time
+	Duration time.Duration
+	Rows     int64 // rows affected by exec, rows returned by queries, -1 if unknown
+	Err      error
+}
+
+// Hook intercepts the operations of a DBServer, see DBServer.Use.
+//
+// Before runs in the order the hooks were added and may rewrite ev.Query/ev.Args.
+// returning an error aborts the operation with that error. the returned context
+// is passed to the next hooks and to the driver, so a hook can attach values or a deadline.
+// After runs in reverse order, also when the operation failed or was aborted,
+// but only for the hooks whose Before was called.
+type Hook interface {
+	Before(ctx context.Context, ev *QueryEvent) (context.Context, error)
+	After(ctx context.Context, ev *QueryEvent)
+}
+
+// HookFuncs adapts plain functions to Hook. nil functions are skipped.
+//
+//	db.Use(easysql.HookFuncs{AfterFunc: func(ctx context.Context, ev *easysql.QueryEvent) {
+//		log.Println(ev.Op, ev.Query, ev.Duration, ev.Err)
+//	}})
+type HookFuncs struct {
+	BeforeFunc func(ctx context.Context, ev *QueryEvent) (context.Context, error)
+	AfterFunc  func(ctx context.Context, ev *QueryEvent)
+}
+
+func (h HookFuncs) Before(ctx context.Context, ev *QueryEvent) (context.Context, error) {
+	if h.BeforeFunc == nil {
+		return ctx, nil
+	}
+	return h.BeforeFunc(ctx, ev)
+}
+
+func (h HookFuncs) After(ctx context.Context, ev *QueryEvent) {
+	if h.AfterFunc != nil {
+		h.AfterFunc(ctx, ev)
+	}
+}
+
+// WithHook adds hooks when the DBServer is created, same as calling DBServer.Use.
+func WithHook(hooks ...Hook) Option {
+	return func(o *Options) {
+		o.Hooks = append(o.Hooks, hooks...)
+	}
+}
+
+// Hooks is the hook chain of a DBServer, used by the backend packages.
+type Hooks struct {
+	mu    sync.RWMutex
+	hooks []Hook
+}
+
+func NewHooks(hooks ...Hook) *Hooks {
+	h := &Hooks{}
+	h.Use(hooks...)
+	return h
+}
+
+func (h *Hooks) Use(hooks ...Hook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, hook := range hooks {
+		if hook == nil {
+			panic("easysql: nil hook")
+		}
+		//copy on write, Run works on a snapshot
+		list := make([]Hook, len(h.hooks), len(h.hooks)+1)
+		copy(list, h.hooks)
+		h.hooks = append(list, hook)
+	}
+}
+
+func (h *Hooks) snapshot() []Hook {
+	if h == nil {
+		return nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.hooks
+}
+
+// Run calls fn with ev.Query and ev.Args between the Before and After hooks.
+// fn returns the row count for ev.Rows. nil-safe.
+func (h *Hooks) Run(ctx context.Context, ev *QueryEvent, fn func(ctx context.Context, query string, args []interface{}) (int64, error)) error {
+	hooks := h.snapshot()
+	ev.Start = time.Now()
+	ev.Rows = -1
+
+	called := 0
+	for _, hook := range hooks {
+		ctx2, err := hook.Before(ctx, ev)
+		called++
+		if ctx2 != nil {
+			ctx = ctx2
+		}
+		if err != nil {
+			ev.Err = err
+			break
+		}
+	}
+
+	if ev.Err == nil {
+		ev.Rows, ev.Err = fn(ctx, ev.Query, ev.Args)
+	}
+	ev.Duration = time.Since(ev.Start)
+
+	for i := called - 1; i >= 0; i-- {
+		hooks[i].After(ctx, ev)
+	}
+	return ev.Err
+}
+
+// DestLen returns the length of the slice dest points to, -1 if dest is not a pointer to a slice.
+// used for QueryEvent.Rows of Select.
+func DestLen(dest interface{}) int64 {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return -1
+	}
+	v = v.Elem()
+	if v.Kind() != reflect.Slice {
+		return -1
+	}
+	return int64(v.Len())
+}
+
+// TruncateDest cuts the slice dest points to back to n elements. used to retry a Select.
+func TruncateDest(dest interface{}, n int64) {
+	v := reflect.ValueOf(dest)
+	if n < 0 || v.Kind() != reflect.Ptr || v.IsNil() {
+		return
+	}
+	v = v.Elem()
+	if v.Kind() == reflect.Slice && int64(v.Len()) > n {
+		v.SetLen(int(n))
+	}
+}
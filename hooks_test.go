@@ -0,0 +1,154 @@
+package easysql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type ctxKey string
+
+// hook logging its calls into log
+func logHook(log *[]string, name string, beforeErr error) Hook {
+	return HookFuncs{
+		BeforeFunc: func(ctx context.Context, ev *QueryEvent) (context.Context, error) {
+			*log = append(*log, "before "+name)
+			return ctx, beforeErr
+		},
+		AfterFunc: func(ctx context.Context, ev *QueryEvent) {
+			*log = append(*log, "after "+name+": "+errString(ev.Err))
+		},
+	}
+}
+
+func TestHooksRunOrder(t *testing.T) {
+	errStmt := errors.New("statement failed")
+	errHook := errors.New("rejected")
+
+	tests := []struct {
+		name    string
+		hookErr error // returned by Before of the second hook
+		fnErr   error
+		want    []string
+	}{
+		{"success", nil, nil, []string{"before 1", "before 2", "before 3", "fn", "after 3: ", "after 2: ", "after 1: "}},
+		{"statement fails", nil, errStmt, []string{"before 1", "before 2", "before 3", "fn",
+			"after 3: statement failed", "after 2: statement failed", "after 1: statement failed"}},
+		// the third hook is never called, the statement doesn't run
+		{"before fails", errHook, nil, []string{"before 1", "before 2", "after 2: rejected", "after 1: rejected"}},
+	}
+
+	for _, tt := range tests {
+		var log []string
+		hooks := NewHooks(logHook(&log, "1", nil), logHook(&log, "2", tt.hookErr), logHook(&log, "3", nil))
+
+		ev := &QueryEvent{Op: OpExec, Query: "update t"}
+		err := hooks.Run(context.Background(), ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+			log = append(log, "fn")
+			return 1, tt.fnErr
+		})
+
+		want := tt.fnErr
+		if tt.hookErr != nil {
+			want = tt.hookErr
+		}
+		if err != want || ev.Err != want {
+			t.Errorf("%s: err %v, ev.Err %v, want %v", tt.name, err, ev.Err, want)
+		}
+		if !reflect.DeepEqual(log, tt.want) {
+			t.Errorf("%s: calls %q, want %q", tt.name, log, tt.want)
+		}
+	}
+}
+
+func TestHooksRunRewrite(t *testing.T) {
+	rewrite := HookFuncs{BeforeFunc: func(ctx context.Context, ev *QueryEvent) (context.Context, error) {
+		ev.Query = "/* app */ " + ev.Query
+		ev.Args = append(ev.Args, 2)
+		return context.WithValue(ctx, ctxKey("tenant"), "acme"), nil
+	}}
+
+	var seen *QueryEvent
+	var afterTenant interface{}
+	check := HookFuncs{
+		BeforeFunc: func(ctx context.Context, ev *QueryEvent) (context.Context, error) {
+			seen = &QueryEvent{Query: ev.Query, Args: ev.Args}
+			return ctx, nil
+		},
+		AfterFunc: func(ctx context.Context, ev *QueryEvent) {
+			afterTenant = ctx.Value(ctxKey("tenant"))
+		},
+	}
+
+	var gotQuery string
+	var gotArgs []interface{}
+	var gotTenant interface{}
+	ev := &QueryEvent{Op: OpExec, Query: "delete from t where a=$1 and b=$2", Args: []interface{}{1}}
+	err := NewHooks(rewrite, check).Run(context.Background(), ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		gotQuery, gotArgs, gotTenant = query, args, ctx.Value(ctxKey("tenant"))
+		return 3, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantQuery := "/* app */ delete from t where a=$1 and b=$2"
+	wantArgs := []interface{}{1, 2}
+	if gotQuery != wantQuery || !reflect.DeepEqual(gotArgs, wantArgs) {
+		t.Errorf("statement ran with %q %v, want %q %v", gotQuery, gotArgs, wantQuery, wantArgs)
+	}
+	if seen == nil || seen.Query != wantQuery {
+		t.Errorf("next hook saw %+v, want the rewritten query", seen)
+	}
+	if gotTenant != "acme" || afterTenant != "acme" {
+		t.Errorf("context value %v in the statement, %v in After, want the one set by Before", gotTenant, afterTenant)
+	}
+	if ev.Rows != 3 || ev.Start.IsZero() || ev.Duration < 0 {
+		t.Errorf("event %+v", ev)
+	}
+}
+
+func TestHooksNil(t *testing.T) {
+	var hooks *Hooks
+	ev := &QueryEvent{Query: "select 1"}
+	called := false
+	err := hooks.Run(context.Background(), ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		called = true
+		return -1, nil
+	})
+	if err != nil || !called {
+		t.Errorf("nil Hooks: err %v, called %v", err, called)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Use(nil) didn't panic")
+		}
+	}()
+	NewHooks().Use(nil)
+}
+
+func TestDestLen(t *testing.T) {
+	s := []int{1, 2, 3}
+	if n := DestLen(&s); n != 3 {
+		t.Errorf("DestLen(&slice) = %d", n)
+	}
+	var x int
+	if n := DestLen(&x); n != -1 {
+		t.Errorf("DestLen(&int) = %d", n)
+	}
+	if n := DestLen(s); n != -1 {
+		t.Errorf("DestLen(slice) = %d", n)
+	}
+
+	TruncateDest(&s, 1)
+	if !reflect.DeepEqual(s, []int{1}) {
+		t.Errorf("TruncateDest: %v", s)
+	}
+	TruncateDest(&s, -1)
+	TruncateDest(&s, 5)
+	if len(s) != 1 {
+		t.Errorf("TruncateDest changed the slice: %v", s)
+	}
+}
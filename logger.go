@@ -0,0 +1,48 @@
+package easysql
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger is the logging interface of easysql, with the same methods as *slog.Logger,
+// so a slog logger can be passed directly. args are alternating keys and values.
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// StdLogger writes through the standard log package as "LEVEL msg key=value ...".
+// used when no Logger is configured.
+var StdLogger Logger = stdLogger{}
+
+type stdLogger struct{}
+
+func (stdLogger) Info(msg string, args ...interface{}) {
+	log.Print(formatLog("INFO", msg, args))
+}
+
+func (stdLogger) Warn(msg string, args ...interface{}) {
+	log.Print(formatLog("WARN", msg, args))
+}
+
+func (stdLogger) Error(msg string, args ...interface{}) {
+	log.Print(formatLog("ERROR", msg, args))
+}
+
+func formatLog(level string, msg string, args []interface{}) string {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&b, " %v=%q", args[i], fmt.Sprint(args[i+1]))
+		} else {
+			fmt.Fprintf(&b, " !BADKEY=%q", fmt.Sprint(args[i]))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,307 @@
+package easysql
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the latency buckets in seconds used when NewMetrics gets none.
+var DefaultBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MaxFingerprints bounds the number of distinct statements tracked by a Metrics,
+// further statements are counted under the fingerprint "other".
+var MaxFingerprints = 1000
+
+// Metrics collects pool stats and per statement counters and latency histograms,
+// and serves them in the Prometheus text format. one Metrics can be shared by several DBServers.
+//
+//	metrics := easysql.NewMetrics()
+//	db, err := postgre.NewWithOptions(dsn, easysql.WithMetrics(metrics))
+//	http.Handle("/metrics", metrics)
+type Metrics struct {
+	buckets []float64
+
+	mu      sync.Mutex
+	queries map[queryKey]*queryStats
+	nFinger map[string]bool
+	pools   []metricsPool
+}
+
+type queryKey struct {
+	system      string
+	op          string
+	fingerprint string
+}
+
+type queryStats struct {
+	ok      uint64
+	errors  uint64
+	rows    uint64
+	sum     float64
+	buckets []uint64 //cumulative counts are computed when writing
+}
+
+type metricsPool struct {
+	name  string
+	stats func() sql.DBStats
+}
+
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		buckets: buckets,
+		queries: make(map[queryKey]*queryStats),
+		nFinger: make(map[string]bool),
+	}
+}
+
+// WithMetrics records the queries and the pool of the DBServer into m.
+func WithMetrics(m *Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = m
+	}
+}
+
+// RegisterPool adds a connection pool to the exported pool stats.
+// name is the pool label, a suffix is added when it is already used.
+func (m *Metrics) RegisterPool(name string, stats func() sql.DBStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	label := name
+	for n := 2; m.hasPool(label); n++ {
+		label = name + "-" + strconv.Itoa(n)
+	}
+	m.pools = append(m.pools, metricsPool{name: label, stats: stats})
+}
+
+func (m *Metrics) hasPool(name string) bool {
+	for _, p := range m.pools {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Metrics) Before(ctx context.Context, ev *QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (m *Metrics) After(ctx context.Context, ev *QueryEvent) {
+	key := queryKey{system: ev.Backend, op: ev.Op, fingerprint: Fingerprint(ev.Query)}
+	seconds := ev.Duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.nFinger[key.fingerprint] {
+		if len(m.nFinger) >= MaxFingerprints {
+			key.fingerprint = "other"
+		} else {
+			m.nFinger[key.fingerprint] = true
+		}
+	}
+
+	st := m.queries[key]
+	if st == nil {
+		st = &queryStats{buckets: make([]uint64, len(m.buckets))}
+		m.queries[key] = st
+	}
+
+	if ev.Err != nil {
+		st.errors++
+	} else {
+		st.ok++
+	}
+	if ev.Rows > 0 {
+		st.rows += uint64(ev.Rows)
+	}
+	st.sum += seconds
+	for i, le := range m.buckets {
+		if seconds <= le {
+			st.buckets[i]++
+			break
+		}
+	}
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	m.writePools(cw)
+	m.writeQueries(cw)
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+func (m *Metrics) writePools(w *countWriter) {
+	m.mu.Lock()
+	pools := append([]metricsPool(nil), m.pools...)
+	m.mu.Unlock()
+
+	if len(pools) == 0 {
+		return
+	}
+
+	stats := make([]sql.DBStats, len(pools))
+	for i, p := range pools {
+		stats[i] = p.stats()
+	}
+
+	gauges := []struct {
+		name, typ, help string
+		value           func(s sql.DBStats) float64
+	}{
+		{"easysql_pool_max_open_connections", "gauge", "Maximum number of open connections.", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"easysql_pool_open_connections", "gauge", "Number of open connections.", func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"easysql_pool_in_use_connections", "gauge", "Number of connections in use.", func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"easysql_pool_idle_connections", "gauge", "Number of idle connections.", func(s sql.DBStats) float64 { return float64(s.Idle) }},
+		{"easysql_pool_wait_count_total", "counter", "Number of connections waited for.", func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"easysql_pool_wait_duration_seconds_total", "counter", "Time blocked waiting for a connection.", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"easysql_pool_max_idle_closed_total", "counter", "Connections closed because of MaxIdleConns.", func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"easysql_pool_max_idle_time_closed_total", "counter", "Connections closed because of ConnMaxIdleTime.", func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"easysql_pool_max_lifetime_closed_total", "counter", "Connections closed because of ConnMaxLifetime.", func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+
+	for _, g := range gauges {
+		w.printf("# HELP %s %s\n# TYPE %s %s\n", g.name, g.help, g.name, g.typ)
+		for i, p := range pools {
+			w.printf("%s{pool=%s} %s\n", g.name, quoteLabel(p.name), formatFloat(g.value(stats[i])))
+		}
+	}
+}
+
+func (m *Metrics) writeQueries(w *countWriter) {
+	type entry struct {
+		key queryKey
+		st  queryStats
+	}
+
+	m.mu.Lock()
+	entries := make([]entry, 0, len(m.queries))
+	for k, st := range m.queries {
+		e := entry{key: k, st: *st}
+		e.st.buckets = append([]uint64(nil), st.buckets...)
+		entries = append(entries, e)
+	}
+	m.mu.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].key, entries[j].key
+		if a.system != b.system {
+			return a.system < b.system
+		}
+		if a.op != b.op {
+			return a.op < b.op
+		}
+		return a.fingerprint < b.fingerprint
+	})
+
+	labels := func(k queryKey) string {
+		return "system=" + quoteLabel(k.system) + ",op=" + quoteLabel(k.op) + ",fingerprint=" + quoteLabel(k.fingerprint)
+	}
+
+	w.printf("# HELP easysql_queries_total Number of database operations.\n# TYPE easysql_queries_total counter\n")
+	for _, e := range entries {
+		w.printf("easysql_queries_total{%s,status=\"ok\"} %d\n", labels(e.key), e.st.ok)
+		w.printf("easysql_queries_total{%s,status=\"error\"} %d\n", labels(e.key), e.st.errors)
+	}
+
+	w.printf("# HELP easysql_query_rows_total Number of rows returned or affected.\n# TYPE easysql_query_rows_total counter\n")
+	for _, e := range entries {
+		w.printf("easysql_query_rows_total{%s} %d\n", labels(e.key), e.st.rows)
+	}
+
+	w.printf("# HELP easysql_query_duration_seconds Latency of database operations.\n# TYPE easysql_query_duration_seconds histogram\n")
+	for _, e := range entries {
+		l := labels(e.key)
+		var cum uint64
+		for i, le := range m.buckets {
+			cum += e.st.buckets[i]
+			w.printf("easysql_query_duration_seconds_bucket{%s,le=\"%s\"} %d\n", l, formatFloat(le), cum)
+		}
+		count := e.st.ok + e.st.errors
+		w.printf("easysql_query_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", l, count)
+		w.printf("easysql_query_duration_seconds_sum{%s} %s\n", l, formatFloat(e.st.sum))
+		w.printf("easysql_query_duration_seconds_count{%s} %d\n", l, count)
+	}
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func quoteLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return `"` + v + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	reStringLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+	reNumber        = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	rePlaceholder   = regexp.MustCompile(`\$\d+|@p\d+|\?`)
+	reList          = regexp.MustCompile(`\?(?:\s*,\s*\?)+`)
+	reValues        = regexp.MustCompile(`\(\?\)(?:\s*,\s*\(\?\))+`)
+	reSpaces        = regexp.MustCompile(`\s+`)
+)
+
+// Fingerprint normalizes a statement for use as a metrics label:
+// literals and placeholders become ?, IN lists and VALUES rows are collapsed,
+// whitespace is squeezed and the result is lower case and at most 200 bytes.
+//
+//	"SELECT * FROM t WHERE id IN ($1, $2, $3) AND name='x'" => "select * from t where id in (?) and name=?"
+func Fingerprint(query string) string {
+	q := reStringLiteral.ReplaceAllString(query, "?")
+	q = rePlaceholder.ReplaceAllString(q, "?")
+	q = reNumber.ReplaceAllString(q, "?")
+	q = reList.ReplaceAllString(q, "?")
+	q = reValues.ReplaceAllString(q, "(?)")
+	q = reSpaces.ReplaceAllString(q, " ")
+	q = strings.ToLower(strings.TrimSpace(q))
+	if len(q) > 200 {
+		q = strings.ToValidUTF8(q[:200], "")
+	}
+	return q
+}
@@ -0,0 +1,63 @@
+package easysql
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		// literals
+		{"SELECT * FROM t WHERE id = 42", "select * from t where id = ?"},
+		{"SELECT * FROM t WHERE price > 3.14", "select * from t where price > ?"},
+		{"SELECT * FROM t WHERE name = 'x'", "select * from t where name = ?"},
+		{"SELECT * FROM t WHERE name = 'it''s'", "select * from t where name = ?"},
+		{"SELECT * FROM t WHERE name = 'a,b' AND id = 1", "select * from t where name = ? and id = ?"},
+		{"SELECT col1 FROM t2", "select col1 from t2"},
+
+		// placeholders
+		{"SELECT * FROM t WHERE a = $1 AND b = $12", "select * from t where a = ? and b = ?"},
+		{"SELECT * FROM t WHERE a = ? AND b = ?", "select * from t where a = ? and b = ?"},
+		{"SELECT * FROM t WHERE a = @p1", "select * from t where a = ?"},
+
+		// IN lists and VALUES rows
+		{"SELECT * FROM t WHERE id IN ($1, $2, $3) AND name='x'", "select * from t where id in (?) and name=?"},
+		{"SELECT * FROM t WHERE id IN (?,?)", "select * from t where id in (?)"},
+		{"SELECT * FROM t WHERE id IN (1, 2, 3, 4)", "select * from t where id in (?)"},
+		{"SELECT * FROM t WHERE name IN ('a', 'b')", "select * from t where name in (?)"},
+		{"INSERT INTO t(a,b) VALUES (1,'x'),(2,'y'), (3, 'z')", "insert into t(a,b) values (?)"},
+		{"INSERT INTO t(a,b) VALUES ($1,$2),($3,$4)", "insert into t(a,b) values (?)"},
+
+		// whitespace
+		{"  SELECT\n\t*   FROM t\r\nWHERE id = 1  ", "select * from t where id = ?"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Fingerprint(tt.query); got != tt.want {
+			t.Errorf("Fingerprint(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestFingerprintSameShape(t *testing.T) {
+	a := Fingerprint("select * from t where id in (1, 2) and name = 'bob'")
+	b := Fingerprint("SELECT *\nFROM t WHERE id IN (7,8,9,10) AND name = 'alice'")
+	if a != b {
+		t.Errorf("%q != %q", a, b)
+	}
+}
+
+func TestFingerprintLength(t *testing.T) {
+	got := Fingerprint("select " + strings.Repeat("é", 300) + " from t")
+	if len(got) > 200 {
+		t.Errorf("%d bytes, want at most 200", len(got))
+	}
+	if !utf8.ValidString(got) {
+		t.Errorf("%q is not valid utf-8", got)
+	}
+}
@@ -0,0 +1,83 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/carr123/easysql"
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// mysql error number => SQLSTATE used by easysql
+var sqlStates = map[uint16]string{
+	1062: easysql.CodeUniqueViolation,
+	1586: easysql.CodeUniqueViolation,
+	1216: easysql.CodeForeignKeyViolation,
+	1217: easysql.CodeForeignKeyViolation,
+	1451: easysql.CodeForeignKeyViolation,
+	1452: easysql.CodeForeignKeyViolation,
+	1048: easysql.CodeNotNullViolation,
+	1364: easysql.CodeNotNullViolation,
+	3819: easysql.CodeCheckViolation,
+	1213: easysql.CodeDeadlock,
+	1205: easysql.CodeLockNotAvailable,
+	3024: easysql.CodeQueryCanceled,
+	1317: easysql.CodeQueryCanceled,
+	1040: easysql.CodeTooManyConnections,
+	1053: easysql.CodeConnectionFailure,
+}
+
+var (
+	reDuplicateKey = regexp.MustCompile("for key '([^']+)'$")
+	reForeignKey   = regexp.MustCompile("\\(`[^`]*`\\.`([^`]+)`, CONSTRAINT `([^`]+)`")
+	reCheck        = regexp.MustCompile("Check constraint '([^']+)'")
+)
+
+// wrap driver errors into *easysql.Error, other errors are returned as they are
+func wrapError(err error, query string) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := easysql.AsError(err); ok {
+		return err
+	}
+
+	var myErr *mysqldriver.MySQLError
+	if errors.As(err, &myErr) {
+		e := &easysql.Error{
+			Code:    sqlStates[myErr.Number],
+			Number:  int(myErr.Number),
+			Message: myErr.Message,
+			Query:   query,
+			Err:     err,
+		}
+
+		switch e.Code {
+		case easysql.CodeUniqueViolation:
+			//"users.username" since mysql 8.0.19, "username" before
+			if m := reDuplicateKey.FindStringSubmatch(myErr.Message); m != nil {
+				e.Constraint = m[1]
+				if pos := strings.LastIndexByte(m[1], '.'); pos > 0 {
+					e.Table, e.Constraint = m[1][:pos], m[1][pos+1:]
+				}
+			}
+		case easysql.CodeForeignKeyViolation:
+			if m := reForeignKey.FindStringSubmatch(myErr.Message); m != nil {
+				e.Table, e.Constraint = m[1], m[2]
+			}
+		case easysql.CodeCheckViolation:
+			if m := reCheck.FindStringSubmatch(myErr.Message); m != nil {
+				e.Constraint = m[1]
+			}
+		}
+		return e
+	}
+
+	if errors.Is(err, mysqldriver.ErrInvalidConn) || errors.Is(err, driver.ErrBadConn) {
+		return &easysql.Error{Code: easysql.CodeConnectionFailure, Message: err.Error(), Query: query, Err: err}
+	}
+
+	return err
+}
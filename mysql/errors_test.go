@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/carr123/easysql"
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+func TestWrapError(t *testing.T) {
+	tests := []struct {
+		number     uint16
+		message    string
+		code       string
+		table      string
+		constraint string
+	}{
+		{
+			1062, "Duplicate entry 'alice' for key 'users.username'",
+			easysql.CodeUniqueViolation, "users", "username",
+		},
+		{
+			1062, "Duplicate entry 'alice' for key 'username'",
+			easysql.CodeUniqueViolation, "", "username",
+		},
+		{
+			//the value may look like the key part
+			1062, "Duplicate entry 'x for key 'y' for key 'PRIMARY'",
+			easysql.CodeUniqueViolation, "", "PRIMARY",
+		},
+		{
+			1451, "Cannot delete or update a parent row: a foreign key constraint fails (`shop`.`orders`, CONSTRAINT `orders_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))",
+			easysql.CodeForeignKeyViolation, "orders", "orders_ibfk_1",
+		},
+		{
+			1452, "Cannot add or update a child row: a foreign key constraint fails (`shop`.`orders`, CONSTRAINT `fk_orders_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))",
+			easysql.CodeForeignKeyViolation, "orders", "fk_orders_user",
+		},
+		{
+			3819, "Check constraint 'chk_age' is violated.",
+			easysql.CodeCheckViolation, "", "chk_age",
+		},
+		{
+			1213, "Deadlock found when trying to get lock; try restarting transaction",
+			easysql.CodeDeadlock, "", "",
+		},
+		{
+			1146, "Table 'shop.missing' doesn't exist",
+			"", "", "",
+		},
+	}
+
+	for _, tt := range tests {
+		myErr := &mysqldriver.MySQLError{Number: tt.number, Message: tt.message}
+		err := wrapError(myErr, "insert ...")
+
+		e, ok := easysql.AsError(err)
+		if !ok {
+			t.Errorf("%d: wrapError returned %T", tt.number, err)
+			continue
+		}
+		if e.Code != tt.code || e.Table != tt.table || e.Constraint != tt.constraint {
+			t.Errorf("%d %q: code %q table %q constraint %q, want %q %q %q",
+				tt.number, tt.message, e.Code, e.Table, e.Constraint, tt.code, tt.table, tt.constraint)
+		}
+		if e.Number != int(tt.number) || e.Query != "insert ..." {
+			t.Errorf("%d: number %d query %q", tt.number, e.Number, e.Query)
+		}
+		if !errors.Is(err, myErr) {
+			t.Errorf("%d: driver error not reachable", tt.number)
+		}
+	}
+}
+
+func TestWrapErrorConnection(t *testing.T) {
+	if err := wrapError(mysqldriver.ErrInvalidConn, ""); !easysql.IsConnectionError(err) {
+		t.Errorf("ErrInvalidConn: IsConnectionError = false")
+	}
+	if err := wrapError(nil, ""); err != nil {
+		t.Errorf("nil: %v", err)
+	}
+
+	plain := errors.New("plain")
+	if err := wrapError(plain, ""); err != plain {
+		t.Errorf("plain error changed to %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/carr123/easysql"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+//ping the server and report its version, replication lag and pool usage.
+//the lag is read from SHOW REPLICA STATUS on the replicas, or on this server when it is a replica.
+//servers older than MySQL 8.0.22 only know SHOW SLAVE STATUS.
+func (this *DBServer) Health(ctx context.Context) easysql.Health {
+	probe := easysql.HealthProbe{Backend: backendName, Version: version, ReplicationLag: replicationLag}
+	return easysql.CheckHealth(ctx, this.db, this.replicas, probe, this.opts.HealthThresholds)
+}
+
+func version(ctx context.Context, db *sqlx.DB) (string, error) {
+	var v string
+	err := db.GetContext(ctx, &v, "SELECT VERSION()")
+	return v, err
+}
+
+func replicationLag(ctx context.Context, db *sqlx.DB) (time.Duration, bool, error) {
+	rows, err := db.QueryxContext(ctx, "SHOW REPLICA STATUS")
+	if isParseError(err) {
+		rows, err = db.QueryxContext(ctx, "SHOW SLAVE STATUS")
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0, false, rows.Err() //not a replica
+	}
+	status := make(map[string]interface{})
+	if err := rows.MapScan(status); err != nil {
+		return 0, false, err
+	}
+
+	for _, key := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		var lag sql.NullString
+		if err := lag.Scan(status[key]); err != nil || !lag.Valid {
+			continue
+		}
+		seconds, err := strconv.ParseInt(lag.String, 10, 64)
+		if err != nil {
+			continue
+		}
+		return time.Duration(seconds) * time.Second, true, nil
+	}
+	return 0, false, nil //replication stopped
+}
+
+//ER_PARSE_ERROR, returned for SHOW REPLICA STATUS by older servers
+func isParseError(err error) bool {
+	var myErr *mysqldriver.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == 1064
+}
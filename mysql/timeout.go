@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+//add the MAX_EXECUTION_TIME optimizer hint to a SELECT, so the server aborts it after d.
+//mysql only supports the hint on SELECT. other statements are left as they are, and so are
+//"WITH ... SELECT" and queries starting with a comment: the server doesn't limit them,
+//only the client side context deadline applies.
+//"select * from t" => "select /*+ MAX_EXECUTION_TIME(1000) */ * from t"
+func maxExecutionTime(query string, d time.Duration) string {
+	if d <= 0 {
+		return query
+	}
+
+	trimmed := strings.TrimLeft(query, " \t\r\n(")
+	if len(trimmed) < 6 || !strings.EqualFold(trimmed[:6], "select") {
+		return query
+	}
+	if len(trimmed) > 6 && isIdentChar(trimmed[6]) {
+		return query
+	}
+	if strings.Contains(strings.ToUpper(query), "MAX_EXECUTION_TIME") {
+		return query
+	}
+
+	pos := len(query) - len(trimmed) + 6
+	return query[:pos] + " /*+ MAX_EXECUTION_TIME(" + strconv.FormatInt(milliseconds(d), 10) + ") */" + query[pos:]
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func milliseconds(d time.Duration) int64 {
+	ms := int64(d / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return ms
+}
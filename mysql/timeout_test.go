@@ -0,0 +1,33 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxExecutionTime(t *testing.T) {
+	tests := []struct {
+		query string
+		d     time.Duration
+		want  string
+	}{
+		{"select * from t", time.Second, "select /*+ MAX_EXECUTION_TIME(1000) */ * from t"},
+		{"  SELECT id from t", 1500 * time.Millisecond, "  SELECT /*+ MAX_EXECUTION_TIME(1500) */ id from t"},
+		{"(select 1) union (select 2)", time.Second, "(select /*+ MAX_EXECUTION_TIME(1000) */ 1) union (select 2)"},
+		{"select 1", time.Microsecond, "select /*+ MAX_EXECUTION_TIME(1) */ 1"},
+		{"select 1", 0, "select 1"},
+		{"select /*+ MAX_EXECUTION_TIME(50) */ 1", time.Second, "select /*+ MAX_EXECUTION_TIME(50) */ 1"},
+		{"update t set a=1", time.Second, "update t set a=1"},
+		{"selectivity", time.Second, "selectivity"},
+
+		//not supported, left as they are
+		{"with c as (select 1) select * from c", time.Second, "with c as (select 1) select * from c"},
+		{"/* report */ select 1", time.Second, "/* report */ select 1"},
+	}
+
+	for _, tt := range tests {
+		if got := maxExecutionTime(tt.query, tt.d); got != tt.want {
+			t.Errorf("maxExecutionTime(%q, %v) = %q, want %q", tt.query, tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package easysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Options holds the pool and connection settings shared by all backends.
+type Options struct {
+	MaxOpenConns    int           //0 means unlimited
+	MaxIdleConns    int           //0 means no idle connections are kept
+	ConnMaxLifetime time.Duration //0 means connections are reused forever
+	ConnMaxIdleTime time.Duration //0 means idle connections are never closed for idleness
+
+	ConnectTimeout       time.Duration //timeout of the initial ping, 0 means no timeout
+	ConnectRetry         int           //extra attempts when the initial ping fails
+	ConnectRetryInterval time.Duration
+
+	RetryPolicy *RetryPolicy //retry of ExecInTx, see DefaultRetryPolicy
+
+	Hooks        []Hook        //see WithHook
+	SlowQueryLog *SlowQueryLog //see WithSlowQueryLog
+	Metrics      *Metrics      //see WithMetrics
+	Tracer       Tracer        //see WithTracer
+
+	QueryTimeout time.Duration //see WithQueryTimeout
+
+	HealthThresholds HealthThresholds //see WithHealthThresholds
+	CircuitBreaker   *CircuitBreaker  //see WithCircuitBreaker
+
+	Replicas              []string //see WithReplicas
+	ReplicaPolicy         ReplicaPolicy
+	ReplicaHealthInterval time.Duration
+
+	// converts replica DSNs, set by the easysql.Open drivers so replicas use the URL form too
+	ConvertDSN func(dsn string) (string, error)
+}
+
+type Option func(*Options)
+
+// WithQueryTimeout sets the default timeout of every statement, see Conn.WithTimeout.
+// besides the client side deadline, the server is asked to stop the statement:
+// statement_timeout on postgre/cockroach, a MAX_EXECUTION_TIME hint on mysql (SELECT only)
+// and max_execution_time on clickhouse.
+func WithQueryTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.QueryTimeout = d
+	}
+}
+
+// AddConvertDSN runs fn on the DSNs of the replicas after ConvertDSN.
+// used by the backend packages to add their settings to the replicas.
+func (o *Options) AddConvertDSN(fn func(dsn string) (string, error)) {
+	prev := o.ConvertDSN
+	if prev == nil {
+		o.ConvertDSN = fn
+		return
+	}
+	o.ConvertDSN = func(dsn string) (string, error) {
+		dsn, err := prev(dsn)
+		if err != nil {
+			return "", err
+		}
+		return fn(dsn)
+	}
+}
+
+// TimeoutContext is context.WithTimeout, without deadline when d <= 0.
+func TimeoutContext(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func WithMaxOpenConns(n int) Option {
+	return func(o *Options) {
+		o.MaxOpenConns = n
+	}
+}
+
+func WithMaxIdleConns(n int) Option {
+	return func(o *Options) {
+		o.MaxIdleConns = n
+	}
+}
+
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(o *Options) {
+		o.ConnMaxLifetime = d
+	}
+}
+
+func WithConnMaxIdleTime(d time.Duration) Option {
+	return func(o *Options) {
+		o.ConnMaxIdleTime = d
+	}
+}
+
+func WithConnectTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.ConnectTimeout = d
+	}
+}
+
+// WithConnectRetry retries the initial connection up to attempts more times,
+// waiting interval between two attempts.
+func WithConnectRetry(attempts int, interval time.Duration) Option {
+	return func(o *Options) {
+		o.ConnectRetry = attempts
+		o.ConnectRetryInterval = interval
+	}
+}
+
+// NewOptions returns the database/sql defaults overridden by opts in order.
+func NewOptions(opts ...Option) *Options {
+	o := &Options{
+		MaxIdleConns:         2,
+		ConnectRetryInterval: time.Second,
+		RetryPolicy:          DefaultRetryPolicy(),
+		HealthThresholds:     DefaultHealthThresholds(),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// Apply sets the pool limits of db.
+func (o *Options) Apply(db *sql.DB) {
+	db.SetMaxOpenConns(o.MaxOpenConns)
+	db.SetMaxIdleConns(o.MaxIdleConns)
+	db.SetConnMaxLifetime(o.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(o.ConnMaxIdleTime)
+}
+
+// Connect opens a pool configured by o and pings it, retrying as configured.
+// it is used by the backend packages.
+func Connect(driverName string, dataSourceName string, o *Options) (*sqlx.DB, error) {
+	db, err := sqlx.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return ConnectDB(db, o)
+}
+
+// ConnectDB is Connect for a pool opened by the caller, e.g. with sql.OpenDB.
+// db is closed if the ping fails.
+func ConnectDB(db *sqlx.DB, o *Options) (*sqlx.DB, error) {
+	o.Apply(db.DB)
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = o.ping(db); err == nil {
+			return db, nil
+		}
+		if attempt >= o.ConnectRetry {
+			break
+		}
+		time.Sleep(o.ConnectRetryInterval)
+	}
+
+	db.Close()
+	return nil, err
+}
+
+func (o *Options) ping(db *sqlx.DB) error {
+	ctx := context.Background()
+	if o.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.ConnectTimeout)
+		defer cancel()
+	}
+	return db.PingContext(ctx)
+}
+
+// ServerHooks returns the built-in hooks enabled by the options followed by Hooks.
+// backend is QueryEvent.Backend, db the pool of the DBServer and replicas its read replicas, or nil.
+func (o *Options) ServerHooks(backend string, db *sqlx.DB, replicas *ReplicaSet) []Hook {
+	var hooks []Hook
+	if o.CircuitBreaker != nil {
+		hooks = append(hooks, NewBreaker(*o.CircuitBreaker))
+	}
+	if o.Tracer != nil {
+		hooks = append(hooks, NewTracingHook(o.Tracer))
+	}
+	if o.Metrics != nil {
+		o.Metrics.RegisterPool(backend, db.Stats)
+		hooks = append(hooks, o.Metrics)
+	}
+	if o.SlowQueryLog != nil {
+		hooks = append(hooks, NewSlowQueryHook(*o.SlowQueryLog, db, replicas))
+	}
+	return append(hooks, o.Hooks...)
+}
@@ -0,0 +1,19 @@
+package postgre
+
+import (
+	"github.com/carr123/easysql"
+)
+
+func init() {
+	easysql.Register("postgres", openURL)
+	easysql.Register("postgresql", openURL)
+}
+
+// lib/pq understands "postgres://root@127.0.0.1:5432/bank?sslmode=disable" as is.
+func openURL(dataSourceName string, opts ...easysql.Option) (easysql.DB, error) {
+	db, err := NewWithOptions(dataSourceName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
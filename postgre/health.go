@@ -0,0 +1,40 @@
+package postgre
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/carr123/easysql"
+	"github.com/jmoiron/sqlx"
+)
+
+//ping the server and report its version, replication lag and pool usage.
+//the lag is the replay delay of the replicas, or of this server when it is a standby.
+func (this *DBServer) Health(ctx context.Context) easysql.Health {
+	probe := easysql.HealthProbe{Backend: backendName, Version: version, ReplicationLag: replicationLag}
+	return easysql.CheckHealth(ctx, this.db, this.replicas, probe, this.opts.HealthThresholds)
+}
+
+func version(ctx context.Context, db *sqlx.DB) (string, error) {
+	var v string
+	err := db.GetContext(ctx, &v, "SHOW server_version")
+	return v, err
+}
+
+//a standby that has replayed everything it received is not lagging, however old its last replayed
+//transaction is: pg_last_xact_replay_timestamp() stands still while the primary is idle.
+const lagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN NULL
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+END`
+
+func replicationLag(ctx context.Context, db *sqlx.DB) (time.Duration, bool, error) {
+	var seconds sql.NullFloat64
+	err := db.GetContext(ctx, &seconds, lagQuery)
+	if err != nil || !seconds.Valid {
+		return 0, false, err //not a standby
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), true, nil
+}
@@ -0,0 +1,217 @@
+package postgre
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carr123/easysql"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestWithStatementTimeout(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		d    time.Duration
+		want string
+	}{
+		{"postgres://root@127.0.0.1:5432/bank", 5 * time.Second, "postgres://root@127.0.0.1:5432/bank?statement_timeout=5000"},
+		{"postgres://root@127.0.0.1:5432/bank?sslmode=disable", time.Second, "postgres://root@127.0.0.1:5432/bank?sslmode=disable&statement_timeout=1000"},
+		{"postgres://root@127.0.0.1:5432/bank?statement_timeout=300", time.Second, "postgres://root@127.0.0.1:5432/bank?statement_timeout=300"},
+		{"postgres://root@127.0.0.1:5432/bank", 0, "postgres://root@127.0.0.1:5432/bank"},
+		{"host=127.0.0.1 dbname=bank", 5 * time.Second, "host=127.0.0.1 dbname=bank statement_timeout=5000"},
+		{"host=127.0.0.1 statement_timeout=300", time.Second, "host=127.0.0.1 statement_timeout=300"},
+		{"", time.Second, "statement_timeout=1000"},
+	}
+
+	for _, tt := range tests {
+		got, err := withStatementTimeout(tt.dsn, tt.d)
+		if err != nil {
+			t.Errorf("withStatementTimeout(%q, %v): %v", tt.dsn, tt.d, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("withStatementTimeout(%q, %v) = %q, want %q", tt.dsn, tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestMilliseconds(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want int64
+	}{
+		{0, 0},
+		{-time.Second, 0},
+		{time.Microsecond, 1},
+		{1500 * time.Millisecond, 1500},
+	}
+
+	for _, tt := range tests {
+		if got := milliseconds(tt.d); got != tt.want {
+			t.Errorf("milliseconds(%v) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+}
+
+// recordConnector records the statements run on its connections
+type recordConnector struct {
+	mu       sync.Mutex
+	queries  []string
+	closed   int
+	failExec string //statement which fails
+}
+
+func (r *recordConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &recordConn{r: r}, nil
+}
+
+func (r *recordConnector) Driver() driver.Driver {
+	return nil
+}
+
+func (r *recordConnector) log() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.queries...)
+}
+
+type recordConn struct {
+	r *recordConnector
+}
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *recordConn) Begin() (driver.Tx, error) {
+	return recordTx{}, nil
+}
+
+type recordTx struct{}
+
+func (recordTx) Commit() error {
+	return nil
+}
+
+func (recordTx) Rollback() error {
+	return nil
+}
+
+func (c *recordConn) Close() error {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.r.closed++
+	return nil
+}
+
+func (c *recordConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.r.queries = append(c.r.queries, query)
+	if query == c.r.failExec {
+		return nil, errors.New("failed")
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func TestPin(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		failExec string
+		want     []string
+		closed   int
+	}{
+		{"default timeout", 5 * time.Second, "", []string{"update t"}, 0},
+		{"shorter", 100 * time.Millisecond, "", []string{"SET statement_timeout = 100", "update t", "RESET statement_timeout"}, 0},
+		{"longer", time.Minute, "", []string{"SET statement_timeout = 60000", "update t", "RESET statement_timeout"}, 0},
+		{"none", 0, "", []string{"SET statement_timeout = 0", "update t", "RESET statement_timeout"}, 0},
+		//a connection which keeps the timeout must not go back to the pool
+		{"reset fails", time.Second, "RESET statement_timeout", []string{"SET statement_timeout = 1000", "update t", "RESET statement_timeout"}, 1},
+	}
+
+	for _, tt := range tests {
+		r := &recordConnector{failExec: tt.failExec}
+		db := sqlx.NewDb(sql.OpenDB(r), "postgres")
+		conn := &Conn{srv: &DBServer{opts: &easysql.Options{QueryTimeout: 5 * time.Second}}, timeout: tt.timeout}
+
+		_, err := conn.limit(context.Background(), db, func(q execAndQuery) (int64, error) {
+			_, err := q.ExecContext(context.Background(), "update t")
+			return -1, err
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if got := r.log(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: statements %q, want %q", tt.name, got, tt.want)
+		}
+		r.mu.Lock()
+		closed := r.closed
+		r.mu.Unlock()
+		if closed != tt.closed {
+			t.Errorf("%s: %d connections closed, want %d", tt.name, closed, tt.closed)
+		}
+		db.Close()
+	}
+}
+
+func TestPinSetFails(t *testing.T) {
+	r := &recordConnector{failExec: "SET statement_timeout = 1000"}
+	db := sqlx.NewDb(sql.OpenDB(r), "postgres")
+	defer db.Close()
+	conn := &Conn{srv: &DBServer{opts: &easysql.Options{}}, timeout: time.Second}
+
+	called := false
+	_, err := conn.limit(context.Background(), db, func(q execAndQuery) (int64, error) {
+		called = true
+		return -1, nil
+	})
+	if err == nil || called {
+		t.Errorf("err = %v, statement called %v", err, called)
+	}
+}
+
+func TestSavepointStatements(t *testing.T) {
+	r := &recordConnector{}
+	db := sqlx.NewDb(sql.OpenDB(r), "postgres")
+	defer db.Close()
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	hooks := easysql.NewHooks(easysql.HookFuncs{AfterFunc: func(ctx context.Context, ev *easysql.QueryEvent) {
+		events = append(events, ev.Op+": "+ev.Query)
+	}})
+	srv := &DBServer{db: db, opts: &easysql.Options{QueryTimeout: 5 * time.Second}, hooks: hooks}
+	conn := &Conn{srv: srv, db: db, tx: tx, excter: tx, callbacks: easysql.NewTxCallbacks(), timeout: 5 * time.Second, txTimeout: srv.txTimeout()}
+
+	errFailed := errors.New("failed")
+	err = conn.ExecInTx(func(c easysql.Conn) error {
+		if err := c.WithTimeout(time.Second).Exec("update t"); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if err != errFailed {
+		t.Errorf("err = %v, want %v", err, errFailed)
+	}
+
+	//no SET LOCAL before ROLLBACK TO, the transaction may be aborted
+	wantQueries := []string{"SAVEPOINT easysql_sp1", "SET LOCAL statement_timeout = 1000", "update t", "ROLLBACK TO SAVEPOINT easysql_sp1", "RELEASE SAVEPOINT easysql_sp1"}
+	if got := r.log(); !reflect.DeepEqual(got, wantQueries) {
+		t.Errorf("statements %q, want %q", got, wantQueries)
+	}
+	wantEvents := []string{"savepoint: SAVEPOINT easysql_sp1", "exec: update t", "savepoint: ROLLBACK TO SAVEPOINT easysql_sp1", "savepoint: RELEASE SAVEPOINT easysql_sp1"}
+	if !reflect.DeepEqual(events, wantEvents) {
+		t.Errorf("hook events %q, want %q", events, wantEvents)
+	}
+	tx.Rollback()
+}
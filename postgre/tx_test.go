@@ -0,0 +1,98 @@
+package postgre
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/carr123/easysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// callbacks of a retried attempt never run, those of the final attempt run once
+func TestExecInTxCallbacksRetried(t *testing.T) {
+	r := &recordConnector{}
+	db := sqlx.NewDb(sql.OpenDB(r), "postgres")
+	defer db.Close()
+	srv := &DBServer{db: db, opts: &easysql.Options{RetryPolicy: &easysql.RetryPolicy{MaxAttempts: 3}}}
+
+	errConflict := &easysql.Error{Code: easysql.CodeSerializationFailure, Err: errors.New("could not serialize access")}
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name string
+		errs []error //returned by the attempts in turn
+		err  error
+		want []string
+	}{
+		{"committed", []error{nil}, nil, []string{"commit 1"}},
+		{"committed after retries", []error{errConflict, errConflict, nil}, nil, []string{"commit 3"}},
+		{"failed after a retry", []error{errConflict, errFailed}, errFailed, []string{"rollback 2: failed"}},
+		{"retries exhausted", []error{errConflict, errConflict, errConflict}, errConflict, []string{"rollback 3: could not serialize access"}},
+	}
+
+	for _, tt := range tests {
+		var log []string
+		attempt := 0
+		err := srv.ExecInTx(func(conn easysql.Conn) error {
+			attempt++
+			name := string(rune('0' + attempt))
+			conn.AfterCommit(func() {
+				log = append(log, "commit "+name)
+			})
+			conn.AfterRollback(func(err error) {
+				log = append(log, "rollback "+name+": "+err.Error())
+			})
+			return tt.errs[attempt-1]
+		})
+
+		if err != tt.err {
+			t.Errorf("%s: err %v, want %v", tt.name, err, tt.err)
+		}
+		if attempt != len(tt.errs) {
+			t.Errorf("%s: %d attempts, want %d", tt.name, attempt, len(tt.errs))
+		}
+		if !reflect.DeepEqual(log, tt.want) {
+			t.Errorf("%s: ran %q, want %q", tt.name, log, tt.want)
+		}
+	}
+}
+
+// callbacks of a rolled back savepoint run with its error, those of a released one wait for the commit
+func TestExecInTxSavepointCallbacks(t *testing.T) {
+	r := &recordConnector{}
+	db := sqlx.NewDb(sql.OpenDB(r), "postgres")
+	defer db.Close()
+	srv := &DBServer{db: db, opts: &easysql.Options{}}
+
+	var log []string
+	errFailed := errors.New("failed")
+	err := srv.ExecInTx(func(conn easysql.Conn) error {
+		conn.ExecInTx(func(sp easysql.Conn) error {
+			sp.AfterCommit(func() {
+				log = append(log, "commit rolled back savepoint")
+			})
+			sp.AfterRollback(func(err error) {
+				log = append(log, "rollback savepoint: "+err.Error())
+			})
+			return errFailed
+		})
+		conn.ExecInTx(func(sp easysql.Conn) error {
+			sp.AfterCommit(func() {
+				log = append(log, "commit released savepoint")
+			})
+			return nil
+		})
+		log = append(log, "end of tx")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"rollback savepoint: failed", "end of tx", "commit released savepoint"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("ran %q, want %q", log, want)
+	}
+}
@@ -0,0 +1,132 @@
+package easysql
+
+import (
+	"encoding/base64"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// one row returned by Conn.Query, keyed by column name
+type QItem map[string]interface{}
+type QArray []QItem
+
+func MakeQArray() QArray {
+	return make(QArray, 0)
+}
+
+func (item QItem) AsMap() map[string]interface{} {
+	return item
+}
+
+// GetColumnString returns "" if the column is missing, NULL or not convertible.
+func (item QItem) GetColumnString(key string) string {
+	return item.String(key)
+}
+
+// GetColumnInt64 returns 0 if the column is missing, NULL or not convertible.
+func (item QItem) GetColumnInt64(key string) int64 {
+	return item.Int64(key)
+}
+
+// GetColumnFloat64 returns 0 if the column is missing, NULL or not convertible.
+func (item QItem) GetColumnFloat64(key string) float64 {
+	return item.Float64(key)
+}
+
+func (item QItem) ToInt64(keys ...string) QItem {
+	for _, key := range keys {
+		if ss, ok := item[key].(string); ok {
+			item[key], _ = strconv.ParseInt(ss, 10, 64)
+		}
+	}
+	return item
+}
+
+func (item QItem) IntToBool(keys ...string) QItem {
+	for _, key := range keys {
+		switch v := item[key].(type) {
+		case string:
+			n, _ := strconv.ParseInt(v, 10, 64)
+			item[key] = n != 0
+		case int64:
+			item[key] = v != 0
+		}
+	}
+	return item
+}
+
+func (item QItem) ToFloat64(keys ...string) QItem {
+	for _, key := range keys {
+		if ss, ok := item[key].(string); ok {
+			item[key], _ = strconv.ParseFloat(ss, 64)
+		}
+	}
+	return item
+}
+
+func (item QItem) Base64Encode(keys ...string) QItem {
+	for _, key := range keys {
+		if ss, ok := item[key].(string); ok {
+			item[key] = base64.StdEncoding.EncodeToString([]byte(ss))
+		}
+	}
+	return item
+}
+
+func (array *QArray) ToInt64(key ...string) *QArray {
+	for _, item := range *array {
+		item.ToInt64(key...)
+	}
+	return array
+}
+
+func (array *QArray) Shuffle() *QArray {
+	nLen := len(*array)
+
+	if nLen < 2 {
+		return array
+	}
+
+	rand.Seed(time.Now().UnixNano())
+	dest := make([]QItem, nLen)
+	perm := rand.Perm(nLen)
+	for i, v := range perm {
+		dest[v] = (*array)[i]
+	}
+
+	*array = dest
+
+	return array
+}
+
+func (array *QArray) IntToBool(key ...string) *QArray {
+	for _, item := range *array {
+		item.IntToBool(key...)
+	}
+	return array
+}
+
+func (array *QArray) ToFloat64(key ...string) *QArray {
+	for _, item := range *array {
+		item.ToFloat64(key...)
+	}
+	return array
+}
+
+func (array *QArray) Base64Encode(key ...string) *QArray {
+	for _, item := range *array {
+		item.Base64Encode(key...)
+	}
+	return array
+}
+
+func (array *QArray) ToRawArray() []interface{} {
+	arr := make([]interface{}, 0, len(*array))
+
+	for _, item := range *array {
+		arr = append(arr, map[string]interface{}(item))
+	}
+
+	return arr
+}
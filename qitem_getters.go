@@ -0,0 +1,223 @@
+package easysql
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Safe getters of QItem. values are coerced between numeric, string and []byte
+// representations, so "12" (mysql), []byte("12") and int64(12) all read as 12.
+//
+//	item.Int64("age")           //0 if missing, NULL or not a number
+//	item.Int64Or("age", 18)     //18 if missing, NULL or not a number
+//	item.GetInt64("age")        //error if missing, NULL or not a number
+//
+// the error wraps ErrColumnNotFound or ErrNullValue when relevant.
+
+func (item QItem) value(key string) (interface{}, error) {
+	v, ok := item[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrColumnNotFound, key)
+	}
+	if v == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNullValue, key)
+	}
+	return v, nil
+}
+
+func columnError(key string, err error) error {
+	return fmt.Errorf("easysql: column %s: %w", key, err)
+}
+
+//------------------------------------------------------------------------------
+func (item QItem) GetString(key string) (string, error) {
+	v, err := item.value(key)
+	if err != nil {
+		return "", err
+	}
+	s, err := toString(v)
+	if err != nil {
+		return "", columnError(key, err)
+	}
+	return s, nil
+}
+
+func (item QItem) StringOr(key string, def string) string {
+	if s, err := item.GetString(key); err == nil {
+		return s
+	}
+	return def
+}
+
+func (item QItem) String(key string) string {
+	return item.StringOr(key, "")
+}
+
+//------------------------------------------------------------------------------
+func (item QItem) GetInt64(key string) (int64, error) {
+	v, err := item.value(key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return 0, columnError(key, err)
+	}
+	return n, nil
+}
+
+func (item QItem) Int64Or(key string, def int64) int64 {
+	if n, err := item.GetInt64(key); err == nil {
+		return n
+	}
+	return def
+}
+
+func (item QItem) Int64(key string) int64 {
+	return item.Int64Or(key, 0)
+}
+
+//------------------------------------------------------------------------------
+func (item QItem) GetFloat64(key string) (float64, error) {
+	v, err := item.value(key)
+	if err != nil {
+		return 0, err
+	}
+	f, err := toFloat64(v)
+	if err != nil {
+		return 0, columnError(key, err)
+	}
+	return f, nil
+}
+
+func (item QItem) Float64Or(key string, def float64) float64 {
+	if f, err := item.GetFloat64(key); err == nil {
+		return f
+	}
+	return def
+}
+
+func (item QItem) Float64(key string) float64 {
+	return item.Float64Or(key, 0)
+}
+
+//------------------------------------------------------------------------------
+func (item QItem) GetBool(key string) (bool, error) {
+	v, err := item.value(key)
+	if err != nil {
+		return false, err
+	}
+	b, err := toBool(v)
+	if err != nil {
+		return false, columnError(key, err)
+	}
+	return b, nil
+}
+
+func (item QItem) BoolOr(key string, def bool) bool {
+	if b, err := item.GetBool(key); err == nil {
+		return b
+	}
+	return def
+}
+
+func (item QItem) Bool(key string) bool {
+	return item.BoolOr(key, false)
+}
+
+//------------------------------------------------------------------------------
+func (item QItem) GetTime(key string) (time.Time, error) {
+	v, err := item.value(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	tm, err := toTime(v)
+	if err != nil {
+		return time.Time{}, columnError(key, err)
+	}
+	return tm, nil
+}
+
+func (item QItem) TimeOr(key string, def time.Time) time.Time {
+	if tm, err := item.GetTime(key); err == nil {
+		return tm
+	}
+	return def
+}
+
+func (item QItem) Time(key string) time.Time {
+	return item.TimeOr(key, time.Time{})
+}
+
+//------------------------------------------------------------------------------
+func (item QItem) GetBytes(key string) ([]byte, error) {
+	v, err := item.value(key)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toBytes(v)
+	if err != nil {
+		return nil, columnError(key, err)
+	}
+	return b, nil
+}
+
+func (item QItem) BytesOr(key string, def []byte) []byte {
+	if b, err := item.GetBytes(key); err == nil {
+		return b
+	}
+	return def
+}
+
+func (item QItem) Bytes(key string) []byte {
+	return item.BytesOr(key, nil)
+}
+
+//------------------------------------------------------------------------------
+// GetJSON decodes a json column into dest. the column may hold json text
+// or a value already decoded by Query.
+func (item QItem) GetJSON(key string, dest interface{}) error {
+	v, err := item.value(key)
+	if err != nil {
+		return err
+	}
+
+	var bin []byte
+	switch x := v.(type) {
+	case []byte:
+		bin = x
+	case string:
+		bin = []byte(x)
+	default:
+		if bin, err = json.Marshal(x); err != nil {
+			return columnError(key, err)
+		}
+	}
+
+	if err := json.Unmarshal(bin, dest); err != nil {
+		return columnError(key, err)
+	}
+	return nil
+}
+
+// JSONOr returns the decoded json value of the column, or def.
+func (item QItem) JSONOr(key string, def interface{}) interface{} {
+	v, err := item.value(key)
+	if err != nil {
+		return def
+	}
+	switch v.(type) {
+	case []byte, string:
+		out, err := toJSON(v)
+		if err != nil {
+			return def
+		}
+		return out
+	}
+	return v
+}
+
+func (item QItem) JSON(key string) interface{} {
+	return item.JSONOr(key, nil)
+}
@@ -0,0 +1,226 @@
+package easysql
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ReplicaPolicy int
+
+const (
+	RoundRobin       ReplicaPolicy = iota
+	LeastConnections               //replica with the fewest connections in use
+)
+
+// WithReplicas sends reads outside transactions (Query, QueryRows, Iterate, Select, Get, QueryCount)
+// to the replicas, in the same DSN format as the primary.
+// unhealthy replicas are skipped and reads fall back to the primary when none is healthy.
+// use Conn.ForcePrimary to read your own writes.
+func WithReplicas(dsns ...string) Option {
+	return func(o *Options) {
+		o.Replicas = append(o.Replicas, dsns...)
+	}
+}
+
+func WithReplicaPolicy(p ReplicaPolicy) Option {
+	return func(o *Options) {
+		o.ReplicaPolicy = p
+	}
+}
+
+// WithReplicaHealthCheck sets how often replicas are pinged, default 5s.
+func WithReplicaHealthCheck(interval time.Duration) Option {
+	return func(o *Options) {
+		o.ReplicaHealthInterval = interval
+	}
+}
+
+// ReplicaSet routes reads to the replicas of a DBServer, used by the backend packages.
+type ReplicaSet struct {
+	replicas []*replica
+	policy   ReplicaPolicy
+	interval time.Duration
+	next     uint32
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+type replica struct {
+	db      *sqlx.DB
+	healthy int32 //1 healthy, 0 down, -1 not checked yet
+}
+
+// NewReplicaSet opens the replicas of o.Replicas. it returns nil if there are none.
+// replicas start out of rotation and are added by the first health check, which runs
+// in the background, so a replica outage doesn't delay or prevent the startup.
+func NewReplicaSet(driverName string, backend string, o *Options) (*ReplicaSet, error) {
+	if len(o.Replicas) == 0 {
+		return nil, nil
+	}
+
+	r := &ReplicaSet{policy: o.ReplicaPolicy, interval: o.ReplicaHealthInterval, stop: make(chan struct{})}
+	if r.interval <= 0 {
+		r.interval = 5 * time.Second
+	}
+
+	for _, dsn := range o.Replicas {
+		if o.ConvertDSN != nil {
+			var err error
+			if dsn, err = o.ConvertDSN(dsn); err != nil {
+				r.Close()
+				return nil, err
+			}
+		}
+
+		db, err := sqlx.Open(driverName, dsn)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		o.Apply(db.DB)
+		if o.Metrics != nil {
+			o.Metrics.RegisterPool(backend+"-replica", db.Stats)
+		}
+
+		r.replicas = append(r.replicas, &replica{db: db, healthy: -1})
+	}
+
+	r.wg.Add(1)
+	go r.healthLoop()
+	return r, nil
+}
+
+func (r *ReplicaSet) healthLoop() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.checkAll()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.checkAll()
+		}
+	}
+}
+
+// ping the replicas concurrently, one unreachable replica doesn't delay the others
+func (r *ReplicaSet) checkAll() {
+	var wg sync.WaitGroup
+	for _, rep := range r.replicas {
+		wg.Add(1)
+		go func(rep *replica) {
+			defer wg.Done()
+			r.check(rep)
+		}(rep)
+	}
+	wg.Wait()
+}
+
+func (r *ReplicaSet) check(rep *replica) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+	defer cancel()
+
+	if err := rep.db.PingContext(ctx); err != nil {
+		if atomic.SwapInt32(&rep.healthy, 0) != 0 {
+			StdLogger.Warn("easysql: replica is down", "error", err)
+		}
+		return
+	}
+	atomic.StoreInt32(&rep.healthy, 1)
+}
+
+// Reader returns a healthy replica, nil if there is none. nil-safe.
+func (r *ReplicaSet) Reader() *sqlx.DB {
+	if r == nil {
+		return nil
+	}
+
+	n := len(r.replicas)
+	switch r.policy {
+	case LeastConnections:
+		var best *replica
+		bestInUse := 0
+		for _, rep := range r.replicas {
+			if atomic.LoadInt32(&rep.healthy) != 1 {
+				continue
+			}
+			if inUse := rep.db.Stats().InUse; best == nil || inUse < bestInUse {
+				best, bestInUse = rep, inUse
+			}
+		}
+		if best != nil {
+			return best.db
+		}
+	default:
+		start := int(atomic.AddUint32(&r.next, 1))
+		for i := 0; i < n; i++ {
+			rep := r.replicas[(start+i)%n]
+			if atomic.LoadInt32(&rep.healthy) == 1 {
+				return rep.db
+			}
+		}
+	}
+	return nil
+}
+
+// MarkDown takes a replica out of rotation until the next successful health check,
+// after a connection error on it.
+func (r *ReplicaSet) MarkDown(db *sqlx.DB) {
+	if r == nil {
+		return
+	}
+	for _, rep := range r.replicas {
+		if rep.db == db {
+			atomic.StoreInt32(&rep.healthy, 0)
+		}
+	}
+}
+
+// Healthy returns the number of healthy replicas and the total. nil-safe.
+func (r *ReplicaSet) Healthy() (healthy int, total int) {
+	if r == nil {
+		return 0, 0
+	}
+	for _, rep := range r.replicas {
+		if atomic.LoadInt32(&rep.healthy) == 1 {
+			healthy++
+		}
+	}
+	return healthy, len(r.replicas)
+}
+
+// DBs returns the replica pools. nil-safe.
+func (r *ReplicaSet) DBs() []*sqlx.DB {
+	if r == nil {
+		return nil
+	}
+	dbs := make([]*sqlx.DB, len(r.replicas))
+	for i, rep := range r.replicas {
+		dbs[i] = rep.db
+	}
+	return dbs
+}
+
+// Close stops the health check and closes the replicas. nil-safe.
+func (r *ReplicaSet) Close() {
+	if r == nil {
+		return
+	}
+	select {
+	case <-r.stop:
+		return
+	default:
+		close(r.stop)
+	}
+	r.wg.Wait()
+	for _, rep := range r.replicas {
+		rep.db.Close()
+	}
+}
@@ -0,0 +1,169 @@
+package easysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeDriver accepts connections to the DSN "up" and refuses the others
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	if dsn != "up" {
+		return nil, errors.New("connection refused")
+	}
+	return fakeDriverConn{}, nil
+}
+
+type fakeDriverConn struct{}
+
+func (fakeDriverConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (fakeDriverConn) Close() error              { return nil }
+func (fakeDriverConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func init() {
+	sql.Register("easysql-fake", fakeDriver{})
+}
+
+func openFake(t *testing.T, dsn string) *sqlx.DB {
+	db, err := sqlx.Open("easysql-fake", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestReplicaSet(policy ReplicaPolicy, dbs ...*sqlx.DB) *ReplicaSet {
+	r := &ReplicaSet{policy: policy, stop: make(chan struct{})}
+	for _, db := range dbs {
+		r.replicas = append(r.replicas, &replica{db: db, healthy: 1})
+	}
+	return r
+}
+
+func TestReplicaSetNil(t *testing.T) {
+	var r *ReplicaSet
+	if r.Reader() != nil {
+		t.Error("nil ReplicaSet returned a reader")
+	}
+	r.MarkDown(nil)
+	if healthy, total := r.Healthy(); healthy != 0 || total != 0 {
+		t.Errorf("Healthy = %d, %d", healthy, total)
+	}
+	r.Close()
+}
+
+func TestReaderRoundRobin(t *testing.T) {
+	a, b, c := openFake(t, "up"), openFake(t, "up"), openFake(t, "up")
+	r := newTestReplicaSet(RoundRobin, a, b, c)
+
+	seen := map[*sqlx.DB]int{}
+	for i := 0; i < 6; i++ {
+		seen[r.Reader()]++
+	}
+	if seen[a] != 2 || seen[b] != 2 || seen[c] != 2 {
+		t.Errorf("reads per replica = %d %d %d, want 2 each", seen[a], seen[b], seen[c])
+	}
+}
+
+func TestReaderSkipsUnhealthy(t *testing.T) {
+	for _, policy := range []ReplicaPolicy{RoundRobin, LeastConnections} {
+		a, b, c := openFake(t, "up"), openFake(t, "up"), openFake(t, "up")
+		r := newTestReplicaSet(policy, a, b, c)
+		r.replicas[0].healthy = 0  //down
+		r.replicas[1].healthy = -1 //not checked yet
+
+		for i := 0; i < 4; i++ {
+			if got := r.Reader(); got != c {
+				t.Fatalf("policy %d: Reader returned an unhealthy replica", policy)
+			}
+		}
+
+		r.MarkDown(c)
+		if got := r.Reader(); got != nil {
+			t.Errorf("policy %d: Reader = %p with every replica down, want nil", policy, got)
+		}
+		if healthy, total := r.Healthy(); healthy != 0 || total != 3 {
+			t.Errorf("policy %d: Healthy = %d, %d", policy, healthy, total)
+		}
+	}
+}
+
+func TestReaderLeastConnections(t *testing.T) {
+	a, b := openFake(t, "up"), openFake(t, "up")
+	r := newTestReplicaSet(LeastConnections, a, b)
+
+	conn, err := a.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		if got := r.Reader(); got != b {
+			t.Fatalf("Reader returned the replica with a connection in use")
+		}
+	}
+}
+
+func TestMarkDown(t *testing.T) {
+	a, b := openFake(t, "up"), openFake(t, "up")
+	r := newTestReplicaSet(RoundRobin, a, b)
+
+	r.MarkDown(a)
+	for i := 0; i < 4; i++ {
+		if got := r.Reader(); got != b {
+			t.Fatal("Reader returned a replica marked down")
+		}
+	}
+	if healthy, total := r.Healthy(); healthy != 1 || total != 2 {
+		t.Errorf("Healthy = %d, %d, want 1, 2", healthy, total)
+	}
+
+	//a DB which is not a replica is ignored
+	r.MarkDown(openFake(t, "up"))
+	if healthy, _ := r.Healthy(); healthy != 1 {
+		t.Errorf("Healthy = %d, want 1", healthy)
+	}
+}
+
+func TestNewReplicaSetChecksInBackground(t *testing.T) {
+	logger := StdLogger
+	StdLogger = discardLogger{}
+	defer func() { StdLogger = logger }()
+
+	o := NewOptions(WithReplicas("up", "down"), WithReplicaHealthCheck(time.Hour))
+	r, err := NewReplicaSet("easysql-fake", "fake", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	//the first check runs in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if healthy, _ := r.Healthy(); healthy == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the reachable replica was not brought into rotation")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	dbs := r.DBs()
+	for i := 0; i < 4; i++ {
+		if got := r.Reader(); got != dbs[0] {
+			t.Fatal("Reader returned the unreachable replica")
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package easysql
+
+import (
+	"database/sql"
+)
+
+// Result is returned by Conn.ExecResult.
+// LastInsertId is only reported by mysql, it is 0 on the other backends;
+// use Conn.InsertReturningID to get a generated id portably.
+type Result struct {
+	RowsAffected int64
+	LastInsertId int64
+}
+
+func NewResult(res sql.Result) Result {
+	var r Result
+	if res == nil {
+		return r
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		r.RowsAffected = n
+	}
+	if id, err := res.LastInsertId(); err == nil {
+		r.LastInsertId = id
+	}
+	return r
+}
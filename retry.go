@@ -0,0 +1,89 @@
+package easysql
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides how DB.ExecInTx reruns a transaction which failed with a
+// serialization failure, a deadlock or a lock wait timeout. the whole transaction
+// is rolled back and fn runs again, so fn must be safe to run more than once.
+type RetryPolicy struct {
+	MaxAttempts    int //total runs including the first one, <=1 disables retries
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         float64 //0..1, the fraction of each backoff which is randomized
+
+	//Retryable classifies errors, nil uses the classifier of the backend:
+	//mysql 1213 (deadlock) and 1205 (lock wait timeout),
+	//postgre/cockroach SQLSTATE 40001 (serialization failure) and 40P01 (deadlock).
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy is used by all backends unless WithRetryPolicy is given,
+// except cockroach which retries more, see cockroach.DefaultRetryPolicy.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 20 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Jitter:         0.5,
+	}
+}
+
+// NoRetry runs every transaction once.
+func NoRetry() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 1}
+}
+
+func WithRetryPolicy(p *RetryPolicy) Option {
+	return func(o *Options) {
+		o.RetryPolicy = p
+	}
+}
+
+// Do runs fn until it succeeds, fails with an error which is not retryable,
+// MaxAttempts is reached or ctx is done. the last error is returned.
+// classify is the backend classifier, overridden by p.Retryable.
+func (p *RetryPolicy) Do(ctx context.Context, classify func(err error) bool, fn func() error) error {
+	if p != nil && p.Retryable != nil {
+		classify = p.Retryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || p == nil || attempt >= p.MaxAttempts || classify == nil || !classify(err) {
+			return err
+		}
+
+		timer := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Backoff returns the wait before the next run after the given attempt (1 based).
+// it doubles from InitialBackoff up to MaxBackoff, randomized by Jitter.
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 && d > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		d -= time.Duration(rand.Float64() * jitter * float64(d))
+	}
+	return d
+}
@@ -0,0 +1,149 @@
+package easysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var (
+	errConflict = errors.New("conflict")
+	errFatal    = errors.New("fatal")
+)
+
+func isConflict(err error) bool {
+	return err == errConflict
+}
+
+// fn failing with errs in turn, then succeeding
+func failing(calls *int, errs ...error) func() error {
+	return func() error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 3}
+
+	tests := []struct {
+		name  string
+		errs  []error
+		err   error
+		calls int
+	}{
+		{"success", nil, nil, 1},
+		{"retried", []error{errConflict, errConflict}, nil, 3},
+		{"max attempts", []error{errConflict, errConflict, errConflict, errConflict}, errConflict, 3},
+		{"not retryable", []error{errFatal}, errFatal, 1},
+		{"not retryable after a retry", []error{errConflict, errFatal}, errFatal, 2},
+	}
+
+	for _, tt := range tests {
+		calls := 0
+		err := p.Do(context.Background(), isConflict, failing(&calls, tt.errs...))
+		if err != tt.err || calls != tt.calls {
+			t.Errorf("%s: err %v after %d calls, want %v after %d", tt.name, err, calls, tt.err, tt.calls)
+		}
+	}
+}
+
+func TestRetryPolicyNoRetry(t *testing.T) {
+	for _, p := range []*RetryPolicy{nil, NoRetry(), {MaxAttempts: 0}} {
+		calls := 0
+		if err := p.Do(context.Background(), isConflict, failing(&calls, errConflict)); err != errConflict || calls != 1 {
+			t.Errorf("%+v: err %v after %d calls, want one call", p, err, calls)
+		}
+	}
+
+	calls := 0
+	p := &RetryPolicy{MaxAttempts: 3}
+	if err := p.Do(context.Background(), nil, failing(&calls, errConflict)); err != errConflict || calls != 1 {
+		t.Errorf("without classifier: err %v after %d calls, want one call", err, calls)
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	// the override retries what the backend doesn't
+	p := &RetryPolicy{MaxAttempts: 3, Retryable: func(err error) bool { return err == errFatal }}
+	calls := 0
+	if err := p.Do(context.Background(), isConflict, failing(&calls, errFatal)); err != nil || calls != 2 {
+		t.Errorf("override: err %v after %d calls, want success after 2", err, calls)
+	}
+
+	// and doesn't retry what the backend would
+	calls = 0
+	if err := p.Do(context.Background(), isConflict, failing(&calls, errConflict)); err != errConflict || calls != 1 {
+		t.Errorf("override: err %v after %d calls, want one call", err, calls)
+	}
+}
+
+func TestRetryPolicyContextDone(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	err := p.Do(ctx, isConflict, failing(&calls, errConflict, errConflict))
+	if err != errConflict || calls != 1 {
+		t.Errorf("err %v after %d calls, want the first error", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do waited %v for the backoff after ctx was done", elapsed)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := &RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 50, 50, 50}
+	for i, w := range want {
+		if got := p.Backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+
+	// no cap
+	p = &RetryPolicy{InitialBackoff: time.Millisecond}
+	if got := p.Backoff(11); got != 1024*time.Millisecond {
+		t.Errorf("uncapped Backoff(11) = %v", got)
+	}
+
+	// a large attempt doesn't overflow
+	p = &RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: time.Second}
+	if got := p.Backoff(1000); got != time.Second {
+		t.Errorf("Backoff(1000) = %v, want MaxBackoff", got)
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	tests := []struct {
+		jitter float64
+		min    time.Duration
+	}{
+		{0.5, 50 * time.Millisecond},
+		{1, 0},
+		{2, 0}, // clamped to 1
+	}
+
+	for _, tt := range tests {
+		p := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: tt.jitter}
+		varied := false
+		for i := 0; i < 200; i++ {
+			d := p.Backoff(1)
+			if d < tt.min || d > 100*time.Millisecond {
+				t.Fatalf("jitter %v: Backoff(1) = %v, want in [%v, 100ms]", tt.jitter, d, tt.min)
+			}
+			if d != p.Backoff(1) {
+				varied = true
+			}
+		}
+		if !varied {
+			t.Errorf("jitter %v: Backoff is not randomized", tt.jitter)
+		}
+	}
+}
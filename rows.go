@@ -0,0 +1,166 @@
+package easysql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Rows is a cursor over a query result, returned by Conn.QueryRows.
+// rows are read from the database one by one, so it suits large results.
+// the cursor is closed when the context of the Conn is canceled.
+//
+//	rows, err := conn.QueryRows("select userid,username from accounts")
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Close()
+//
+//	for rows.Next() {
+//		var user User
+//		if err := rows.Scan(&user); err != nil {
+//			return err
+//		}
+//	}
+//	return rows.Err()
+type Rows struct {
+	rows   *sqlx.Rows
+	cols   []string
+	kinds  []columnKind
+	cancel context.CancelFunc
+}
+
+func NewRows(rows *sqlx.Rows) *Rows {
+	return &Rows{rows: rows}
+}
+
+// NewRowsWithCancel is NewRows for a query running under its own deadline,
+// cancel is called when the rows are closed.
+func NewRowsWithCancel(rows *sqlx.Rows, cancel context.CancelFunc) *Rows {
+	return &Rows{rows: rows, cancel: cancel}
+}
+
+func (r *Rows) Next() bool {
+	return r.rows.Next()
+}
+
+// Scan reads the current row into a struct (mapped by db tags)
+// or into one scalar destination per column.
+func (r *Rows) Scan(dest ...interface{}) error {
+	if len(dest) == 1 && isStructDest(dest[0]) {
+		return r.rows.StructScan(dest[0])
+	}
+	return r.rows.Scan(dest...)
+}
+
+// Item reads the current row as a QItem. the values are converted by column type to
+// int64, float64, bool, string, time.Time, []byte or decoded json, whatever the backend.
+// DECIMAL and NUMERIC are exact strings, UNSIGNED BIGINT above math.MaxInt64 is uint64.
+func (r *Rows) Item() (QItem, error) {
+	if r.cols == nil {
+		if err := r.loadColumns(); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make([]interface{}, len(r.cols))
+	ptrs := make([]interface{}, len(r.cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := r.rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+
+	item := make(QItem, len(r.cols))
+	for i, col := range r.cols {
+		item[col] = convertColumn(r.kinds[i], values[i])
+	}
+	return item, nil
+}
+
+// All reads the remaining rows and closes the cursor.
+func (r *Rows) All() (QArray, error) {
+	result := make(QArray, 0, 100)
+	err := r.ForEach(func(row QItem) error {
+		result = append(result, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ForEach calls fn for every remaining row and closes the cursor.
+// iteration stops at the first error returned by fn.
+func (r *Rows) ForEach(fn func(row QItem) error) error {
+	defer r.Close()
+
+	for r.Next() {
+		item, err := r.Item()
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return r.Err()
+}
+
+func (r *Rows) Columns() ([]string, error) {
+	return r.rows.Columns()
+}
+
+func (r *Rows) loadColumns() error {
+	types, err := r.rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+
+	cols := make([]string, len(types))
+	kinds := make([]columnKind, len(types))
+	for i, ct := range types {
+		cols[i] = ct.Name()
+		kinds[i] = columnKindOf(ct)
+	}
+	r.cols, r.kinds = cols, kinds
+	return nil
+}
+
+func (r *Rows) Err() error {
+	return r.rows.Err()
+}
+
+func (r *Rows) Close() error {
+	err := r.rows.Close()
+	if r.cancel != nil {
+		r.cancel()
+	}
+	return err
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// a pointer to a struct with exported fields which is not a sql.Scanner itself.
+// time.Time and easysql.DATE etc. are scanned as a single column.
+func isStructDest(dest interface{}) bool {
+	t := reflect.TypeOf(dest)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	if t.Implements(scannerType) {
+		return false
+	}
+
+	t = t.Elem()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package easysql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SlowQueryLog logs the statements that take longer than Threshold.
+//
+//	mysql.NewWithOptions(dsn, easysql.WithSlowQueryLog(easysql.SlowQueryLog{
+//		Threshold: 200 * time.Millisecond,
+//		Logger:    slog.Default(),
+//		Explain:   true,
+//	}))
+type SlowQueryLog struct {
+	Threshold time.Duration
+	Logger    Logger //nil means StdLogger
+
+	// run EXPLAIN on slow reads in the background and log the plan.
+	// reads outside transactions are explained on a replica when there are replicas.
+	// a statement (by Fingerprint) is explained at most once per ExplainInterval, and
+	// at most ExplainConcurrency EXPLAINs run at once, others are skipped, so a brownout
+	// where every query is slow doesn't get worse.
+	Explain            bool
+	ExplainTimeout     time.Duration //default 5s
+	ExplainInterval    time.Duration //default 1m
+	ExplainConcurrency int           //default 2
+
+	// args are logged through Redact, default RedactArg. return arg to log it as is.
+	Redact func(arg interface{}) interface{}
+}
+
+func WithSlowQueryLog(cfg SlowQueryLog) Option {
+	return func(o *Options) {
+		o.SlowQueryLog = &cfg
+	}
+}
+
+// RedactArg keeps numbers, bools, times and NULLs, and hides the content of
+// strings and bytes, which may hold personal data or secrets.
+func RedactArg(arg interface{}) interface{} {
+	switch v := arg.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, time.Time:
+		return v
+	case string:
+		return "<string len=" + strconv.Itoa(len(v)) + ">"
+	case []byte:
+		return "<bytes len=" + strconv.Itoa(len(v)) + ">"
+	}
+	return "<" + reflect.TypeOf(arg).String() + ">"
+}
+
+// NewSlowQueryHook returns the hook installed by WithSlowQueryLog.
+// db and replicas are used for EXPLAIN, db may be nil when Explain is off and replicas nil without replicas.
+func NewSlowQueryHook(cfg SlowQueryLog, db *sqlx.DB, replicas *ReplicaSet) Hook {
+	if cfg.Logger == nil {
+		cfg.Logger = StdLogger
+	}
+	if cfg.Redact == nil {
+		cfg.Redact = RedactArg
+	}
+	if cfg.ExplainTimeout <= 0 {
+		cfg.ExplainTimeout = 5 * time.Second
+	}
+	if cfg.ExplainInterval <= 0 {
+		cfg.ExplainInterval = time.Minute
+	}
+	if cfg.ExplainConcurrency <= 0 {
+		cfg.ExplainConcurrency = 2
+	}
+	return &slowQueryHook{
+		cfg:       cfg,
+		db:        db,
+		replicas:  replicas,
+		sem:       make(chan struct{}, cfg.ExplainConcurrency),
+		explained: make(map[string]time.Time),
+	}
+}
+
+type slowQueryHook struct {
+	cfg      SlowQueryLog
+	db       *sqlx.DB
+	replicas *ReplicaSet
+	sem      chan struct{} //EXPLAINs running
+
+	mu        sync.Mutex
+	explained map[string]time.Time //fingerprint => last EXPLAIN
+}
+
+func (h *slowQueryHook) Before(ctx context.Context, ev *QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *slowQueryHook) After(ctx context.Context, ev *QueryEvent) {
+	if ev.Duration < h.cfg.Threshold || ev.Op == OpTx {
+		return
+	}
+
+	args := make([]interface{}, len(ev.Args))
+	for i, arg := range ev.Args {
+		args[i] = h.cfg.Redact(arg)
+	}
+
+	kv := []interface{}{
+		"backend", ev.Backend,
+		"op", ev.Op,
+		"duration", ev.Duration,
+		"rows", ev.Rows,
+		"query", ev.Query,
+		"args", args,
+		"caller", caller(),
+	}
+	if ev.Err != nil {
+		kv = append(kv, "error", ev.Err)
+	}
+	h.cfg.Logger.Warn("easysql: slow query", kv...)
+
+	if !h.cfg.Explain || h.db == nil || !isRead(ev.Op) || !h.shouldExplain(ev.Query) {
+		return
+	}
+
+	db := h.db
+	if !ev.InTx {
+		if replica := h.replicas.Reader(); replica != nil {
+			db = replica
+		}
+	}
+
+	select {
+	case h.sem <- struct{}{}:
+		go func() {
+			defer func() { <-h.sem }()
+			h.explain(db, ev.Backend, ev.Query, ev.Args)
+		}()
+	default:
+	}
+}
+
+// once per ExplainInterval for each fingerprint
+func (h *slowQueryHook) shouldExplain(query string) bool {
+	fingerprint := Fingerprint(query)
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if last, ok := h.explained[fingerprint]; ok && now.Sub(last) < h.cfg.ExplainInterval {
+		return false
+	}
+	if len(h.explained) >= MaxFingerprints {
+		for k, last := range h.explained {
+			if now.Sub(last) >= h.cfg.ExplainInterval {
+				delete(h.explained, k)
+			}
+		}
+		if len(h.explained) >= MaxFingerprints {
+			return false
+		}
+	}
+	h.explained[fingerprint] = now
+	return true
+}
+
+func (h *slowQueryHook) explain(db *sqlx.DB, backend string, query string, args []interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.ExplainTimeout)
+	defer cancel()
+
+	plan, err := Explain(ctx, db, query, args...)
+	if err != nil {
+		h.cfg.Logger.Error("easysql: explain failed", "backend", backend, "query", query, "error", err)
+		return
+	}
+	h.cfg.Logger.Info("easysql: slow query plan", "backend", backend, "query", query, "plan", plan)
+}
+
+func isRead(op string) bool {
+	return op == OpQuery || op == OpSelect || op == OpGet || op == OpCount
+}
+
+// Explain runs "EXPLAIN query" and returns the plan, one line per row with the columns separated by " | ".
+// the statement is not executed.
+func Explain(ctx context.Context, db *sqlx.DB, query string, args ...interface{}) (string, error) {
+	rows, err := db.QueryxContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		cols, err := rows.SliceScan()
+		if err != nil {
+			return "", err
+		}
+		fields := make([]string, len(cols))
+		for i, col := range cols {
+			if b, ok := col.([]byte); ok {
+				col = string(b)
+			}
+			fields[i] = fmt.Sprint(col)
+		}
+		lines = append(lines, strings.Join(fields, " | "))
+	}
+	return strings.Join(lines, "\n"), rows.Err()
+}
+
+var pkgPath = reflect.TypeOf(Error{}).PkgPath()
+
+// file:line of the first caller outside easysql and its backend packages
+func caller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isInternal(frame.Function) {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func isInternal(function string) bool {
+	//"github.com/carr123/easysql/mysql.(*Conn).Exec" => "github.com/carr123/easysql/mysql"
+	pkg := function
+	slash := strings.LastIndex(pkg, "/")
+	if dot := strings.Index(pkg[slash+1:], "."); dot >= 0 {
+		pkg = pkg[:slash+1+dot]
+	}
+
+	switch pkg {
+	case pkgPath, pkgPath + "/mysql", pkgPath + "/postgre", pkgPath + "/cockroach", pkgPath + "/clickhouse":
+		return true
+	}
+	return strings.HasPrefix(pkg, "github.com/jmoiron/sqlx") || strings.HasPrefix(pkg, "database/sql")
+}
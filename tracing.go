@@ -0,0 +1,152 @@
+package easysql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Tracer starts spans, a thin layer so any tracing library can be plugged in.
+// an OpenTelemetry adapter is a few lines:
+//
+//	type otelTracer struct{ t trace.Tracer }
+//
+//	func (o otelTracer) Start(ctx context.Context, name string, attrs ...easysql.Attribute) (context.Context, easysql.Span) {
+//		ctx, span := o.t.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		s := otelSpan{span}
+//		s.SetAttributes(attrs...)
+//		return ctx, s
+//	}
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// WithTracer starts a span for every statement and transaction of the DBServer.
+// spans are named "easysql.<op>" and carry db.system, db.operation, db.statement
+// and db.row_count. statements inside ExecInTx are children of the "easysql.tx" span.
+func WithTracer(t Tracer) Option {
+	return func(o *Options) {
+		o.Tracer = t
+	}
+}
+
+type spanKey struct{}
+
+// NewTracingHook returns the hook installed by WithTracer.
+func NewTracingHook(t Tracer) Hook {
+	return &tracingHook{tracer: t}
+}
+
+type tracingHook struct {
+	tracer Tracer
+}
+
+func (h *tracingHook) Before(ctx context.Context, ev *QueryEvent) (context.Context, error) {
+	attrs := []Attribute{
+		Attr("db.system", ev.Backend),
+		Attr("db.operation", ev.Op),
+	}
+	if ev.Query != "" {
+		attrs = append(attrs, Attr("db.statement", ev.Query))
+	}
+
+	ctx, span := h.tracer.Start(ctx, "easysql."+ev.Op, attrs...)
+	return context.WithValue(ctx, spanKey{}, span), nil
+}
+
+func (h *tracingHook) After(ctx context.Context, ev *QueryEvent) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return
+	}
+
+	if ev.Rows >= 0 {
+		span.SetAttributes(Attr("db.row_count", ev.Rows))
+	}
+	if ev.Err != nil {
+		span.RecordError(ev.Err)
+	}
+	span.End()
+}
+
+// MemoryTracer keeps the spans in memory, for tests.
+type MemoryTracer struct {
+	mu    sync.Mutex
+	spans []*MemorySpan
+}
+
+type MemorySpan struct {
+	Name      string
+	Parent    *MemorySpan
+	Attrs     map[string]interface{}
+	Err       error
+	StartTime time.Time
+	EndTime   time.Time //zero until End is called
+
+	tracer *MemoryTracer
+}
+
+func NewMemoryTracer() *MemoryTracer {
+	return &MemoryTracer{}
+}
+
+type memorySpanKey struct{}
+
+func (t *MemoryTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	span := &MemorySpan{Name: name, Attrs: make(map[string]interface{}), StartTime: time.Now(), tracer: t}
+	span.Parent, _ = ctx.Value(memorySpanKey{}).(*MemorySpan)
+	span.SetAttributes(attrs...)
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return context.WithValue(ctx, memorySpanKey{}, span), span
+}
+
+// Spans returns the started spans in start order.
+func (t *MemoryTracer) Spans() []*MemorySpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*MemorySpan(nil), t.spans...)
+}
+
+func (t *MemoryTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = nil
+}
+
+func (s *MemorySpan) SetAttributes(attrs ...Attribute) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	for _, a := range attrs {
+		s.Attrs[a.Key] = a.Value
+	}
+}
+
+func (s *MemorySpan) RecordError(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Err = err
+}
+
+func (s *MemorySpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.EndTime = time.Now()
+}
@@ -0,0 +1,97 @@
+package easysql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTracingHook(t *testing.T) {
+	tracer := NewMemoryTracer()
+	hooks := NewHooks(NewTracingHook(tracer))
+	errStmt := errors.New("duplicate key")
+
+	// a transaction running a select, then a failing insert
+	tx := &QueryEvent{Op: OpTx, Backend: "postgresql", InTx: true}
+	err := hooks.Run(context.Background(), tx, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		sel := &QueryEvent{Op: OpSelect, Backend: "postgresql", Query: "select id from t", InTx: true}
+		hooks.Run(ctx, sel, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+			return 2, nil
+		})
+
+		ins := &QueryEvent{Op: OpExec, Backend: "postgresql", Query: "insert into t values ($1)", InTx: true}
+		return -1, hooks.Run(ctx, ins, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+			return -1, errStmt
+		})
+	})
+	if err != errStmt {
+		t.Fatalf("err = %v, want %v", err, errStmt)
+	}
+
+	spans := tracer.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("%d spans, want 3", len(spans))
+	}
+
+	tests := []struct {
+		name   string
+		parent *MemorySpan
+		attrs  map[string]interface{}
+		err    error
+	}{
+		{"easysql.tx", nil, map[string]interface{}{
+			"db.system": "postgresql", "db.operation": "tx",
+		}, errStmt},
+		{"easysql.select", spans[0], map[string]interface{}{
+			"db.system": "postgresql", "db.operation": "select", "db.statement": "select id from t", "db.row_count": int64(2),
+		}, nil},
+		{"easysql.exec", spans[0], map[string]interface{}{
+			"db.system": "postgresql", "db.operation": "exec", "db.statement": "insert into t values ($1)",
+		}, errStmt},
+	}
+
+	for i, tt := range tests {
+		span := spans[i]
+		if span.Name != tt.name {
+			t.Errorf("span %d: name %q, want %q", i, span.Name, tt.name)
+		}
+		if span.Parent != tt.parent {
+			t.Errorf("%s: parent %v, want %v", tt.name, span.Parent, tt.parent)
+		}
+		if !reflect.DeepEqual(span.Attrs, tt.attrs) {
+			t.Errorf("%s: attributes %v, want %v", tt.name, span.Attrs, tt.attrs)
+		}
+		if span.Err != tt.err {
+			t.Errorf("%s: error %v, want %v", tt.name, span.Err, tt.err)
+		}
+		if span.EndTime.IsZero() || span.EndTime.Before(span.StartTime) {
+			t.Errorf("%s: not ended", tt.name)
+		}
+	}
+
+	tracer.Reset()
+	if n := len(tracer.Spans()); n != 0 {
+		t.Errorf("%d spans after Reset", n)
+	}
+}
+
+// a statement rejected by an earlier hook gets no span
+func TestTracingHookRejected(t *testing.T) {
+	tracer := NewMemoryTracer()
+	errReject := errors.New("circuit open")
+	reject := HookFuncs{BeforeFunc: func(ctx context.Context, ev *QueryEvent) (context.Context, error) {
+		return ctx, errReject
+	}}
+
+	ev := &QueryEvent{Op: OpQuery, Backend: "mysql", Query: "select 1"}
+	err := NewHooks(reject, NewTracingHook(tracer)).Run(context.Background(), ev, func(ctx context.Context, query string, args []interface{}) (int64, error) {
+		return -1, nil
+	})
+	if err != errReject {
+		t.Errorf("err = %v", err)
+	}
+	if n := len(tracer.Spans()); n != 0 {
+		t.Errorf("%d spans, want none", n)
+	}
+}
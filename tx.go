@@ -0,0 +1,214 @@
+package easysql
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+const (
+	LevelDefault         = sql.LevelDefault
+	LevelReadUncommitted = sql.LevelReadUncommitted
+	LevelReadCommitted   = sql.LevelReadCommitted
+	LevelRepeatableRead  = sql.LevelRepeatableRead
+	LevelSerializable    = sql.LevelSerializable
+)
+
+// TxOptions of DB.ExecInTxWithOptions. options a backend does not support are ignored.
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+
+	//postgre only, meaningful with LevelSerializable and ReadOnly
+	Deferrable bool
+
+	//cockroach only. AsOfSystemTime is an sql expression such as "'-10s'" or
+	//"follower_read_timestamp()", it must not come from user input.
+	//Priority is one of "LOW", "NORMAL", "HIGH".
+	AsOfSystemTime string
+	Priority       string
+}
+
+// SQLOptions converts to the options of database/sql. nil stays nil.
+func (o *TxOptions) SQLOptions() *sql.TxOptions {
+	if o == nil {
+		return nil
+	}
+	return &sql.TxOptions{Isolation: o.Isolation, ReadOnly: o.ReadOnly}
+}
+
+// RunInSavepoint runs fn inside "SAVEPOINT name". the savepoint is rolled back if fn fails
+// and released otherwise. exec runs the savepoint statements in the transaction of the Conn,
+// through its hooks. it backs Conn.ExecInTx of a Conn already in a transaction.
+func RunInSavepoint(name string, exec func(cmd string) error, fn func() error) error {
+	if err := exec("SAVEPOINT " + name); err != nil {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		if rerr := exec("ROLLBACK TO SAVEPOINT " + name); rerr != nil {
+			return fmt.Errorf("%w (rollback to savepoint %s: %v)", err, name, rerr)
+		}
+		if rerr := exec("RELEASE SAVEPOINT " + name); rerr != nil {
+			return fmt.Errorf("%w (release savepoint %s: %v)", err, name, rerr)
+		}
+		return err
+	}
+
+	return exec("RELEASE SAVEPOINT " + name)
+}
+
+// Tx is a transaction driven by the caller, returned by DB.Begin. it embeds the Conn API.
+// always defer Close: it rolls the transaction back and logs a warning
+// if neither Commit nor Rollback was called.
+//
+//	tx, err := db.Begin(ctx, nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer tx.Close()
+//
+//	if err := tx.Exec("update accounts set age=age+1 where userid=?", 1); err != nil {
+//		return err
+//	}
+//	return tx.Commit()
+type Tx struct {
+	Conn
+
+	mu        sync.Mutex
+	done      bool
+	callbacks *TxCallbacks
+	commit    func() error
+	rollback  func() error
+}
+
+// NewTx is used by the backend packages. conn must run its statements in the transaction
+// and register its AfterCommit/AfterRollback callbacks in callbacks.
+func NewTx(conn Conn, callbacks *TxCallbacks, commit func() error, rollback func() error) *Tx {
+	return &Tx{Conn: conn, callbacks: callbacks, commit: commit, rollback: rollback}
+}
+
+func (t *Tx) Commit() error {
+	if !t.finish() {
+		return sql.ErrTxDone
+	}
+	err := t.commit()
+	t.callbacks.Fire(err)
+	return err
+}
+
+// Rollback calls the AfterRollback callbacks with a nil error.
+func (t *Tx) Rollback() error {
+	if !t.finish() {
+		return sql.ErrTxDone
+	}
+	err := t.rollback()
+	t.callbacks.fireRollback(nil)
+	return err
+}
+
+// Close rolls back a transaction which was neither committed nor rolled back.
+// it does nothing otherwise, so it is safe to defer.
+func (t *Tx) Close() error {
+	if t.Done() {
+		return nil
+	}
+	StdLogger.Warn("easysql: transaction was neither committed nor rolled back, rolling back")
+	err := t.Rollback()
+	if err == sql.ErrTxDone {
+		return nil
+	}
+	return err
+}
+
+// Done reports whether Commit or Rollback has been called.
+func (t *Tx) Done() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.done
+}
+
+func (t *Tx) finish() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return false
+	}
+	t.done = true
+	return true
+}
+
+// TxCallbacks collects the Conn.AfterCommit and Conn.AfterRollback callbacks of a
+// transaction attempt. it is used by the backend packages: a new one is created for every
+// attempt, so callbacks registered by attempts which were retried never run,
+// and Fire is called once with the final outcome.
+type TxCallbacks struct {
+	mu       sync.Mutex
+	parent   *TxCallbacks
+	commit   []func()
+	rollback []func(error)
+}
+
+func NewTxCallbacks() *TxCallbacks {
+	return &TxCallbacks{}
+}
+
+// Savepoint returns the callbacks of a savepoint nested in c.
+func (c *TxCallbacks) Savepoint() *TxCallbacks {
+	return &TxCallbacks{parent: c}
+}
+
+func (c *TxCallbacks) AfterCommit(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commit = append(c.commit, fn)
+}
+
+func (c *TxCallbacks) AfterRollback(fn func(error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rollback = append(c.rollback, fn)
+}
+
+// Fire reports the outcome: err == nil means committed (or for a savepoint, released
+// into its parent transaction), otherwise rolled back because of err.
+// callbacks run at most once. c may be nil.
+func (c *TxCallbacks) Fire(err error) {
+	if c == nil {
+		return
+	}
+	if err != nil {
+		c.fireRollback(err)
+		return
+	}
+
+	commit, rollback := c.take()
+	if c.parent != nil {
+		c.parent.mu.Lock()
+		c.parent.commit = append(c.parent.commit, commit...)
+		c.parent.rollback = append(c.parent.rollback, rollback...)
+		c.parent.mu.Unlock()
+		return
+	}
+	for _, fn := range commit {
+		fn()
+	}
+}
+
+func (c *TxCallbacks) fireRollback(err error) {
+	if c == nil {
+		return
+	}
+	_, rollback := c.take()
+	for _, fn := range rollback {
+		fn(err)
+	}
+}
+
+func (c *TxCallbacks) take() ([]func(), []func(error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	commit, rollback := c.commit, c.rollback
+	c.commit, c.rollback = nil, nil
+	return commit, rollback
+}
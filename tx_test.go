@@ -0,0 +1,190 @@
+package easysql
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// exec recording the savepoint statements, failing those in fail
+func recordExec(stmts *[]string, fail map[string]error) func(cmd string) error {
+	return func(cmd string) error {
+		*stmts = append(*stmts, cmd)
+		return fail[strings.Fields(cmd)[0]]
+	}
+}
+
+func TestRunInSavepoint(t *testing.T) {
+	errFn := errors.New("fn failed")
+	errExec := errors.New("exec failed")
+
+	tests := []struct {
+		name  string
+		fnErr error
+		fail  map[string]error
+		stmts []string
+		err   string // "" for nil
+	}{
+		{"released", nil, nil, []string{"SAVEPOINT sp", "RELEASE SAVEPOINT sp"}, ""},
+		{"rolled back", errFn, nil, []string{"SAVEPOINT sp", "ROLLBACK TO SAVEPOINT sp", "RELEASE SAVEPOINT sp"}, "fn failed"},
+		{"savepoint fails", nil, map[string]error{"SAVEPOINT": errExec}, []string{"SAVEPOINT sp"}, "exec failed"},
+		{"release fails", nil, map[string]error{"RELEASE": errExec}, []string{"SAVEPOINT sp", "RELEASE SAVEPOINT sp"}, "exec failed"},
+		{"rollback fails", errFn, map[string]error{"ROLLBACK": errExec}, []string{"SAVEPOINT sp", "ROLLBACK TO SAVEPOINT sp"},
+			"fn failed (rollback to savepoint sp: exec failed)"},
+		{"release after rollback fails", errFn, map[string]error{"RELEASE": errExec}, []string{"SAVEPOINT sp", "ROLLBACK TO SAVEPOINT sp", "RELEASE SAVEPOINT sp"},
+			"fn failed (release savepoint sp: exec failed)"},
+	}
+
+	for _, tt := range tests {
+		var stmts []string
+		called := false
+		err := RunInSavepoint("sp", recordExec(&stmts, tt.fail), func() error {
+			called = true
+			return tt.fnErr
+		})
+
+		if !reflect.DeepEqual(stmts, tt.stmts) {
+			t.Errorf("%s: statements %q, want %q", tt.name, stmts, tt.stmts)
+		}
+		if got := errString(err); got != tt.err {
+			t.Errorf("%s: err %q, want %q", tt.name, got, tt.err)
+		}
+		if tt.fnErr != nil && !errors.Is(err, tt.fnErr) {
+			t.Errorf("%s: err %v doesn't wrap the error of fn", tt.name, err)
+		}
+		if called != (tt.fail["SAVEPOINT"] == nil) {
+			t.Errorf("%s: fn called %v", tt.name, called)
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// callbacks recording what ran into log
+func record(c *TxCallbacks, log *[]string, name string) {
+	c.AfterCommit(func() {
+		*log = append(*log, "commit "+name)
+	})
+	c.AfterRollback(func(err error) {
+		*log = append(*log, "rollback "+name+": "+errString(err))
+	})
+}
+
+func TestTxCallbacks(t *testing.T) {
+	errTx := errors.New("tx failed")
+
+	tests := []struct {
+		name string
+		run  func(c *TxCallbacks, log *[]string)
+		want []string
+	}{
+		{"commit", func(c *TxCallbacks, log *[]string) {
+			c.Fire(nil)
+			c.Fire(nil)
+			c.Fire(errTx)
+		}, []string{"commit tx"}},
+		{"rollback", func(c *TxCallbacks, log *[]string) {
+			c.Fire(errTx)
+			c.Fire(errTx)
+			c.Fire(nil)
+		}, []string{"rollback tx: tx failed"}},
+		{"savepoint rolled back", func(c *TxCallbacks, log *[]string) {
+			sp := c.Savepoint()
+			record(sp, log, "sp")
+			sp.Fire(errTx)
+			c.Fire(nil)
+		}, []string{"rollback sp: tx failed", "commit tx"}},
+		{"savepoint released then committed", func(c *TxCallbacks, log *[]string) {
+			sp := c.Savepoint()
+			record(sp, log, "sp")
+			sp.Fire(nil)
+			c.Fire(nil)
+		}, []string{"commit tx", "commit sp"}},
+		{"savepoint released then rolled back", func(c *TxCallbacks, log *[]string) {
+			sp := c.Savepoint()
+			record(sp, log, "sp")
+			sp.Fire(nil)
+			c.Fire(errTx)
+		}, []string{"rollback tx: tx failed", "rollback sp: tx failed"}},
+		{"nested savepoints", func(c *TxCallbacks, log *[]string) {
+			sp1 := c.Savepoint()
+			record(sp1, log, "sp1")
+			sp2 := sp1.Savepoint()
+			record(sp2, log, "sp2")
+			sp2.Fire(nil)
+			sp1.Fire(errTx)
+			c.Fire(nil)
+		}, []string{"rollback sp1: tx failed", "rollback sp2: tx failed", "commit tx"}},
+	}
+
+	for _, tt := range tests {
+		var log []string
+		c := NewTxCallbacks()
+		record(c, &log, "tx")
+		tt.run(c, &log)
+
+		if !reflect.DeepEqual(log, tt.want) {
+			t.Errorf("%s: ran %q, want %q", tt.name, log, tt.want)
+		}
+	}
+
+	var c *TxCallbacks
+	c.Fire(nil) // nil-safe
+}
+
+func TestTxOnce(t *testing.T) {
+	errCommit := errors.New("commit failed")
+
+	tests := []struct {
+		name   string
+		end    func(tx *Tx) error
+		commit error
+		want   []string
+	}{
+		{"commit", (*Tx).Commit, nil, []string{"commit tx"}},
+		{"commit fails", (*Tx).Commit, errCommit, []string{"rollback tx: commit failed"}},
+		{"rollback", (*Tx).Rollback, nil, []string{"rollback tx: "}},
+	}
+
+	for _, tt := range tests {
+		var log []string
+		calls := 0
+		callbacks := NewTxCallbacks()
+		record(callbacks, &log, "tx")
+		end := func() error {
+			calls++
+			return tt.commit
+		}
+		tx := NewTx(nil, callbacks, end, end)
+
+		if err := tt.end(tx); err != tt.commit {
+			t.Errorf("%s: err %v, want %v", tt.name, err, tt.commit)
+		}
+		if !tx.Done() {
+			t.Errorf("%s: not done", tt.name)
+		}
+		if err := tx.Commit(); err != sql.ErrTxDone {
+			t.Errorf("%s: second Commit: %v", tt.name, err)
+		}
+		if err := tx.Rollback(); err != sql.ErrTxDone {
+			t.Errorf("%s: second Rollback: %v", tt.name, err)
+		}
+		if err := tx.Close(); err != nil {
+			t.Errorf("%s: Close: %v", tt.name, err)
+		}
+
+		if calls != 1 {
+			t.Errorf("%s: ended %d times", tt.name, calls)
+		}
+		if !reflect.DeepEqual(log, tt.want) {
+			t.Errorf("%s: ran %q, want %q", tt.name, log, tt.want)
+		}
+	}
+}
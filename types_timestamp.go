@@ -182,6 +182,8 @@ func (t *DATETIME) SetNULL() *DATETIME {
 func parseTimeString(szDateTime string, loc *time.Location) (time.Time, error) {
 	layouts := []string{
 		"2006-01-02 15:04:05",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02",
 		time.RFC3339,
 		time.RFC3339Nano,
 		time.ANSIC,